@@ -4,10 +4,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/backplane/ghlatest/extract"
 	"github.com/backplane/ghlatest/util"
@@ -26,19 +30,136 @@ func getFilterList(c *cli.Context) util.FilterSet {
 		filters = append(filters, regexp.MustCompile("(?i)"+filterString))
 	}
 
-	// process the --current-arch flag
-	if c.Bool("current-arch") {
+	// process the --arch flag, or the --current-arch flag
+	if c.String("arch") != "" {
+		filters = append(filters, archRegexpFor(strings.ToLower(c.String("arch"))))
+	} else if c.Bool("current-arch") {
 		filters = append(filters, archRegexp)
 	}
 
-	// process the --current-os flag
-	if c.Bool("current-os") {
+	// process the --os flag, or the --current-os flag
+	if c.String("os") != "" {
+		filters = append(filters, osRegexpFor(strings.ToLower(c.String("os"))))
+	} else if c.Bool("current-os") {
 		filters = append(filters, osRegexp)
 	}
 
+	// process the --current-libc flag, the C library is only known for the
+	// current OS
+	if c.Bool("current-libc") {
+		if len(c.StringSlice("platforms")) > 0 || (c.String("os") != "" && !strings.EqualFold(c.String("os"), runtime.GOOS)) {
+			log.Warnf("the C library is only known for this system's OS, ignoring --current-libc")
+		} else if libc := hostLibc(); libc == "" {
+			log.Warnf("the C library of this system couldn't be determined, ignoring --current-libc")
+		} else {
+			log.Debugf("filtering for the %s C library", libc)
+			filters = append(filters, libcRegexpFor(libc))
+		}
+	}
+
 	return filters
 }
 
+// getPlatforms processes the --platforms argument, it returns nil if the
+// argument wasn't given
+func getPlatforms(c *cli.Context) ([]platform, error) {
+	if c.Bool("auto") && c.Bool("source") {
+		return nil, fmt.Errorf("the auto option doesn't make sense for source downloads")
+	}
+	if len(c.StringSlice("platforms")) == 0 {
+		return nil, nil
+	}
+	if c.String("os") != "" || c.String("arch") != "" || c.Bool("current-os") || c.Bool("current-arch") {
+		return nil, fmt.Errorf("the platforms option can't be combined with the os, arch, current-os or current-arch options")
+	}
+	if c.Bool("source") {
+		return nil, fmt.Errorf("the platforms option doesn't make sense for source downloads")
+	}
+	platforms := make([]platform, 0)
+	for _, s := range c.StringSlice("platforms") {
+		p, err := parsePlatform(s)
+		if err != nil {
+			return nil, err
+		}
+		platforms = append(platforms, p)
+	}
+	return platforms, nil
+}
+
+// getTargetPlatform returns the platform given by the --os and --arch
+// arguments, each defaulting to the current one. The C library is only known
+// for the current OS, it's detected for --auto, which scores the assets by it.
+func getTargetPlatform(c *cli.Context) platform {
+	p := currentPlatform()
+	if c.String("os") != "" && !strings.EqualFold(c.String("os"), p.os) {
+		p.os = strings.ToLower(c.String("os"))
+	} else if c.Bool("auto") {
+		p.libc = hostLibc()
+	}
+	if c.String("arch") != "" {
+		p.arch = strings.ToLower(c.String("arch"))
+	}
+	return p
+}
+
+// platformAssets resolves the latest release of the given repo to the assets
+// which match the filters for each of the given platforms. With auto set the
+// assets aren't filtered by platform, instead the best scoring one is picked
+// for each platform.
+func platformAssets(owner string, repo string, filters util.FilterSet, platforms []platform, auto bool) ([][]string, error) {
+	release, err := latestRelease(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	assets := make([][]string, len(platforms))
+	for i, p := range platforms {
+		if auto {
+			asset, err := autoSelectAsset(filterAssets(release, filters), p)
+			if err != nil {
+				return nil, err
+			}
+			assets[i] = []string{asset.GetBrowserDownloadURL()}
+			continue
+		}
+		assets[i] = releaseAssetURLs(release, append(p.regexps(), filters...), false)
+		log.Debugf("found %d matching downloads for %s", len(assets[i]), p)
+	}
+	return assets, nil
+}
+
+func getExtractOptions(c *cli.Context) (extract.Options, error) {
+	onError, err := extract.ParseErrorMode(c.String("on-error"))
+	if err != nil {
+		return extract.Options{}, err
+	}
+	nested := 0
+	if c.Bool("nested") {
+		if nested = c.Int("nested-depth"); nested < 1 {
+			return extract.Options{}, fmt.Errorf("the nested-depth must be at least 1")
+		}
+	}
+	return extract.Options{
+		Overwrite: c.Bool("overwrite"),
+		SameOwner: c.Bool("same-owner"),
+		Xattrs:    c.Bool("xattrs"),
+		OnError:   onError,
+		Nested:    nested,
+		DryRun:    c.Bool("dry-run"),
+		Format:    c.String("format"),
+		Bin:       c.Bool("bin"),
+	}, nil
+}
+
+// getMode processes the mode argument
+func getMode(c *cli.Context) (os.FileMode, error) {
+	// fixme: consider adding support for symbolic modes
+	mode, err := strconv.ParseUint(c.String("mode"), 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("could not process given mode string %s", c.String("mode"))
+	}
+	return os.FileMode(mode), nil
+}
+
 func jsonHandler(c *cli.Context) error {
 	// extract the owner and repo names from the given URL argument
 	if c.NArg() != 1 {
@@ -81,6 +202,31 @@ func listHandler(c *cli.Context) error {
 		return err
 	}
 
+	platforms, err := getPlatforms(c)
+	if err != nil {
+		return err
+	}
+	if platforms != nil || c.Bool("auto") {
+		targets := platforms
+		if targets == nil {
+			targets = []platform{getTargetPlatform(c)}
+		}
+		assets, err := platformAssets(owner, repo, getFilterList(c), targets, c.Bool("auto"))
+		if err != nil {
+			return err
+		}
+		for i, p := range targets {
+			for _, assetURL := range assets[i] {
+				if platforms != nil {
+					fmt.Printf("%s\t%s\n", p, assetURL)
+				} else {
+					fmt.Println(assetURL)
+				}
+			}
+		}
+		return nil
+	}
+
 	for _, assetURL := range latestReleasedAssets(owner, repo, getFilterList(c), c.Bool("source")) {
 		fmt.Println(assetURL)
 	}
@@ -100,12 +246,54 @@ func downloadHandler(c *cli.Context) error {
 		return err
 	}
 
+	// with --platforms, each platform's asset goes into an os/arch directory
+	platforms, err := getPlatforms(c)
+	if err != nil {
+		return err
+	}
+	if platforms != nil || c.Bool("auto") {
+		if platforms != nil && c.String("outputpath") == "-" {
+			return fmt.Errorf("the platforms option can't be used when writing the download to stdout")
+		}
+		targets := platforms
+		if targets == nil {
+			targets = []platform{getTargetPlatform(c)}
+		}
+		assets, err := platformAssets(owner, repo, getFilterList(c), targets, c.Bool("auto"))
+		if err != nil {
+			return err
+		}
+		for i, p := range targets {
+			if len(assets[i]) != 1 {
+				return fmt.Errorf("found %d matching downloads for %s, use a -f flag to get the match count down to exactly 1", len(assets[i]), p)
+			}
+		}
+		for i, p := range targets {
+			dir := ""
+			if platforms != nil {
+				dir = filepath.FromSlash(p.String())
+			}
+			log.Infof("downloading the %s asset %s", p, assets[i][0])
+			if err := downloadAsset(c, owner, repo, assets[i][0], dir); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
 	// determine the assetsURL
 	assets := latestReleasedAssets(owner, repo, getFilterList(c), c.Bool("source"))
 	if len(assets) != 1 {
 		log.Fatalf("found %d matching downloads, use a -f flag to get the match count down to exactly 1\n", len(assets))
 	}
-	assetURL := assets[0]
+	return downloadAsset(c, owner, repo, assets[0], "")
+}
+
+// downloadAsset downloads (and extracts, lists or removes, as the flags say)
+// the release asset at the given URL. If dir is given the output goes into
+// that directory.
+func downloadAsset(c *cli.Context, owner string, repo string, assetURL string, dir string) error {
+	var err error
 
 	// process the optional output path argument
 	var outputpath string
@@ -126,36 +314,124 @@ func downloadHandler(c *cli.Context) error {
 		if !filenameRegexp.MatchString(outputpath) {
 			return fmt.Errorf("could not correctly calculate an output filename from %s", assetURL)
 		}
+
+		// when not extracting, the --keep rename rules apply to the asset itself
+		if !c.Bool("extract") && !c.Bool("list-contents") {
+			keep, err := util.CompileKeepRules(c.StringSlice("keep"))
+			if err != nil {
+				return err
+			}
+			if renamed := keep.Rename(outputpath); renamed != outputpath {
+				log.Debugf("renaming %s to %s", outputpath, renamed)
+				outputpath = renamed
+				if !c.Bool("dry-run") {
+					if err := util.NewParentDirectories(outputpath, 0755); err != nil {
+						return err
+					}
+				}
+			}
+		}
 	}
 
-	// process the mode argument
-	// fixme: consider making this a function and adding support for symbolic modes
-	mode, err := strconv.ParseUint(c.String("mode"), 8, 32)
+	if c.Bool("extract") && c.Bool("list-contents") {
+		return fmt.Errorf("the extract and list-contents options can't be used together")
+	}
+	if outputpath == "-" && (c.Bool("extract") || c.Bool("list-contents") || c.Bool("remove-archive")) {
+		return fmt.Errorf("writing the download to stdout can't be combined with the extract, list-contents or remove-archive options")
+	}
+	var extractOpts extract.Options
+	if c.Bool("extract") || c.Bool("list-contents") {
+		if extractOpts, err = getExtractOptions(c); err != nil {
+			return err
+		}
+	}
+	if dir != "" {
+		// the extracted files go into the directory, as well as the download
+		extractOpts.OutputDir = dir
+		if !c.Bool("extract") {
+			outputpath = filepath.Join(dir, outputpath)
+			if !c.Bool("dry-run") {
+				if err := util.NewParentDirectories(outputpath, 0755); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	mode, err := getMode(c)
 	if err != nil {
-		return fmt.Errorf("could not process given mode string %s", c.String("mode"))
+		return err
+	}
+
+	if c.Bool("remove-archive") && !c.Bool("extract") && !c.Bool("list-contents") {
+		return fmt.Errorf("the remove-archive option doesn't make sense unless you also specify extract or list-contents")
+	}
+	if c.Bool("bin") && !c.Bool("extract") {
+		return fmt.Errorf("the bin option doesn't make sense unless you also specify extract")
+	}
+
+	if c.Bool("dry-run") {
+		return downloadDryRun(c, assetURL, outputpath, mode, extractOpts)
+	}
+
+	// write the download to stdout, the logs go to stderr
+	if outputpath == "-" {
+		body, err := util.OpenURL(assetURL)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		n, err := io.Copy(os.Stdout, body)
+		if err != nil {
+			return fmt.Errorf("writing %s to stdout failed; error: %s", assetURL, err)
+		}
+		log.Infof("wrote %d bytes to stdout", n)
+		return nil
+	}
+
+	// unpack the download as it arrives, the archive itself is never written
+	if c.Bool("extract") {
+		body, err := util.OpenURL(assetURL)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+
+		result, err := extract.ExtractReader(body, outputpath, mode, c.StringSlice("keep"), extractOpts)
+		if result != nil {
+			result.LogSummary()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to extract the archive \"%s\"; error: %s", outputpath, err)
+		}
+		if extractOpts.Bin {
+			printExecutables(result)
+		}
+		if c.Bool("remove-archive") {
+			log.Debugf("the archive was extracted as it was downloaded, there's nothing to remove")
+		}
+		return nil
 	}
 
 	// do the download
-	err = util.DownloadFile(assets[0], outputpath, os.FileMode(mode), c.Bool("overwrite"))
+	err = util.DownloadFile(assetURL, outputpath, mode, c.Bool("overwrite"))
 	if err != nil {
 		return err
 	}
 
-	// unpack the download
-	if c.Bool("extract") {
-		extract.ExtractFile(outputpath, c.StringSlice("keep"), c.Bool("overwrite"))
+	// list the contents of the download
+	if c.Bool("list-contents") {
+		if err := listArchive(outputpath, c.StringSlice("keep"), extractOpts, c.Bool("json")); err != nil {
+			return err
+		}
 	}
 
 	// cleanup the download
 	if c.Bool("remove-archive") {
-		if !c.Bool("extract") {
-			log.Fatalf("the remove-archive option doesn't make sense unless you also specify extract")
-		}
-
 		if err = os.Remove(outputpath); err != nil {
 			log.Fatalf("failed to remove the downloaded archive \"%s\", error: %s", outputpath, err)
 		}
-		log.Infof("removed \"%s\" after extraction", outputpath)
+		log.Infof("removed \"%s\" after listing its contents", outputpath)
 	}
 
 	return nil
@@ -163,14 +439,51 @@ func downloadHandler(c *cli.Context) error {
 
 func extractHandler(c *cli.Context) error {
 	if c.NArg() != 1 {
-		return fmt.Errorf("you must supply a file to extract")
+		return fmt.Errorf("you must supply a file to extract (or \"-\" to read it from stdin)")
 	}
 	archivePath := c.Args().Get(0)
 
-	if err := extract.ExtractFile(archivePath, c.StringSlice("keep"), c.Bool("overwrite")); err != nil {
-		log.Errorf("failed to extract the archive \"%s\"; error: %s", archivePath, err)
+	opts, err := getExtractOptions(c)
+	if err != nil {
 		return err
 	}
+	if archivePath == "-" && c.Bool("remove-archive") {
+		return fmt.Errorf("the remove-archive option can't be used when reading the archive from stdin")
+	}
+	if c.Bool("list") {
+		return listArchive(archivePath, c.StringSlice("keep"), opts, c.Bool("json"))
+	}
+
+	var result *extract.Result
+	if archivePath == "-" {
+		var mode os.FileMode
+		if mode, err = getMode(c); err != nil {
+			return err
+		}
+		result, err = extract.ExtractReader(os.Stdin, archivePath, mode, c.StringSlice("keep"), opts)
+	} else {
+		result, err = extract.ExtractFile(archivePath, c.StringSlice("keep"), opts)
+	}
+	if result != nil {
+		result.LogSummary()
+	}
+	if opts.DryRun {
+		plan := newPlanWriter()
+		plan.addResult(result)
+		if err == nil && c.Bool("remove-archive") {
+			plan.add("remove", archivePath, "")
+		}
+		plan.Flush()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract the archive \"%s\"; error: %s", archivePath, err)
+	}
+	if opts.DryRun {
+		return nil
+	}
+	if opts.Bin {
+		printExecutables(result)
+	}
 
 	// cleanup the download
 	if c.Bool("remove-archive") {
@@ -183,3 +496,556 @@ func extractHandler(c *cli.Context) error {
 
 	return nil
 }
+
+// printExecutables prints the paths of the executables found by an
+// extraction with the bin option, one per line
+func printExecutables(res *extract.Result) {
+	for _, path := range res.Executables() {
+		fmt.Println(path)
+	}
+}
+
+func installHandler(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("you must supply a repo URL argument")
+	}
+	owner, repo, err := repoURLInfo(c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+
+	if err := checkInstallName(c.String("name")); err != nil {
+		return err
+	}
+	binDir := c.String("bin-dir")
+	if binDir == "" {
+		if binDir, err = defaultBinDir(); err != nil {
+			return err
+		}
+	}
+	opts := installOptions{
+		binDir:    binDir,
+		filters:   c.StringSlice("filter"),
+		pin:       c.String("pin"),
+		keep:      c.StringSlice("keep"),
+		name:      c.String("name"),
+		overwrite: c.Bool("overwrite"),
+	}
+	for _, filterString := range c.StringSlice("ifilter") {
+		opts.filters = append(opts.filters, "(?i)"+filterString)
+	}
+
+	// reinstalling may replace the files of the earlier install
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+	if prev := state.find(owner + "/" + repo); prev != nil {
+		opts.owned = prev.options().owned
+	}
+
+	// only the assets for the current platform are considered
+	release, err := resolveRelease(owner, repo, opts.pin)
+	if err != nil {
+		return err
+	}
+	asset, err := resolveAsset(release, opts)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("dry-run") {
+		plan := newPlanWriter()
+		dir, err := versionDir(state.dir, owner+"/"+repo, release.GetTagName())
+		if err != nil {
+			return err
+		}
+		plan.add("download", asset.GetBrowserDownloadURL(), release.GetTagName())
+		plan.add("install", dir, "the executables found in the download")
+		plan.add("link", binDir, "to the executables in "+dir)
+		plan.Flush()
+		return nil
+	}
+
+	rec, err := installRelease(owner, repo, release, asset, opts)
+	if err != nil {
+		return err
+	}
+	for _, path := range rec.installedPaths() {
+		fmt.Println(path)
+	}
+	return nil
+}
+
+func installedHandler(c *cli.Context) error {
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		doc, err := json.MarshalIndent(state.Installs, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(doc))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REPO\tTAG\tASSET\tINSTALLED\tVERSIONS\tFILES")
+	for _, rec := range state.Installs {
+		v := rec.active()
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", rec.Repo, rec.Tag, v.Asset, v.InstalledAt.Local().Format("2006-01-02 15:04"),
+			strings.Join(rec.tags(), ","), strings.Join(rec.installedPaths(), " "))
+	}
+	return w.Flush()
+}
+
+func outdatedHandler(c *cli.Context) error {
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+	recs, err := selectInstalls(state, c.Args().Slice())
+	if err != nil {
+		return err
+	}
+
+	outdated, failed := 0, 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REPO\tINSTALLED\tAVAILABLE\tPIN\tSTATUS")
+	for _, check := range checkUpdates(recs) {
+		pin := check.rec.Pin
+		if pin == "" {
+			pin = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", check.rec.Repo, check.rec.Tag, check.available(), pin, check.status())
+		if check.outdated() {
+			outdated++
+		}
+		if check.err != nil {
+			failed++
+		}
+	}
+	w.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("checking %d installed tools for updates failed", failed)
+	}
+	if outdated > 0 {
+		return cli.Exit(fmt.Sprintf("%d installed tools have updates available", outdated), 1)
+	}
+	return nil
+}
+
+func upgradeHandler(c *cli.Context) error {
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+	recs, err := selectInstalls(state, c.Args().Slice())
+	if err != nil {
+		return err
+	}
+
+	plan := newPlanWriter()
+	failed := 0
+	for _, check := range checkUpdates(recs) {
+		if check.err != nil {
+			failed++
+			continue
+		}
+		// held installs are only upgraded when they're named
+		if check.held() && c.NArg() == 0 {
+			log.Infof("%s is held at %s, name it to upgrade it to %s", check.rec.Repo, check.rec.Tag, check.available())
+			continue
+		}
+		if !check.outdated() && !check.held() {
+			log.Infof("%s is already at %s", check.rec.Repo, check.rec.Tag)
+			continue
+		}
+		if c.Bool("dry-run") {
+			plan.add("upgrade", check.rec.Repo, check.rec.Tag+" => "+check.available())
+			continue
+		}
+		rec, err := upgradeInstall(check)
+		if err != nil {
+			log.Errorf("upgrading %s failed; error: %s", check.rec.Repo, err)
+			failed++
+			continue
+		}
+		for _, path := range rec.installedPaths() {
+			fmt.Println(path)
+		}
+	}
+	plan.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("%d installed tools could not be upgraded", failed)
+	}
+	return nil
+}
+
+func uninstallHandler(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("you must supply a repo URL argument")
+	}
+	owner, repo, err := repoURLInfo(c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+	rec := state.find(owner + "/" + repo)
+	if rec == nil {
+		return fmt.Errorf("%s/%s isn't installed", owner, repo)
+	}
+
+	if c.Bool("dry-run") {
+		plan := newPlanWriter()
+		for _, path := range rec.installedPaths() {
+			plan.add("remove", path, "")
+		}
+		for _, v := range rec.Versions {
+			for _, f := range v.Files {
+				plan.add("remove", f.Path, "")
+			}
+		}
+		for _, dir := range rec.Dirs {
+			plan.add("remove", dir, "if it's empty")
+		}
+		plan.Flush()
+		return nil
+	}
+
+	if err := uninstall(rec, c.Bool("force")); err != nil {
+		return err
+	}
+	state.remove(rec.Repo)
+	return state.save()
+}
+
+func useHandler(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("you must supply an owner/repo@tag argument")
+	}
+	at := strings.LastIndex(c.Args().Get(0), "@")
+	if at < 0 {
+		return fmt.Errorf("you must give the tag to use as owner/repo@tag")
+	}
+	tag := c.Args().Get(0)[at+1:]
+	owner, repo, err := repoURLInfo(c.Args().Get(0)[:at])
+	if err != nil {
+		return err
+	}
+
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+	rec := state.find(owner + "/" + repo)
+	if rec == nil {
+		return fmt.Errorf("%s/%s isn't installed", owner, repo)
+	}
+
+	// versions which aren't in the store yet are installed first
+	if rec.version(tag) == nil {
+		release, err := releaseByTag(owner, repo, tag)
+		if err != nil {
+			return err
+		}
+		opts := rec.options()
+		opts.overwrite = c.Bool("overwrite")
+		opts.hold = true
+		asset, err := resolveAsset(release, opts)
+		if err != nil {
+			return err
+		}
+		if c.Bool("dry-run") {
+			plan := newPlanWriter()
+			plan.add("download", asset.GetBrowserDownloadURL(), tag)
+			plan.add("link", rec.BinDir, "to "+tag)
+			plan.Flush()
+			return nil
+		}
+		if rec, err = installRelease(owner, repo, release, asset, opts); err != nil {
+			return err
+		}
+	} else {
+		if err := switchVersion(c, state, rec, tag); err != nil {
+			return err
+		}
+	}
+	for _, path := range rec.installedPaths() {
+		fmt.Println(path)
+	}
+	return nil
+}
+
+func rollbackHandler(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("you must supply a repo URL argument")
+	}
+	owner, repo, err := repoURLInfo(c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+
+	state, err := loadState()
+	if err != nil {
+		return err
+	}
+	rec := state.find(owner + "/" + repo)
+	if rec == nil {
+		return fmt.Errorf("%s/%s isn't installed", owner, repo)
+	}
+	if rec.Previous == "" {
+		return fmt.Errorf("%s has no previous version to roll back to", rec.Repo)
+	}
+	if err := switchVersion(c, state, rec, rec.Previous); err != nil {
+		return err
+	}
+	for _, path := range rec.installedPaths() {
+		fmt.Println(path)
+	}
+	return nil
+}
+
+func syncHandler(c *cli.Context) error {
+	m, err := loadManifest(c.String("file"))
+	if err != nil {
+		return err
+	}
+	var lock *lockFile
+	if c.Bool("frozen") {
+		if lock, err = loadLock(m.lockPath()); err != nil {
+			return err
+		}
+		if err := lock.check(m); err != nil {
+			return err
+		}
+	}
+	results, err := syncTools(m, lock, c.Int("jobs"), c.Bool("dry-run"))
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REPO\tTAG\tSTATUS")
+	for _, res := range results {
+		tag, status := res.tag, res.status
+		if tag == "" {
+			tag = "-"
+		}
+		if res.err != nil {
+			status = "error"
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", res.repo, tag, status)
+	}
+	w.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of the tools in %s could not be synced", failed, m.path)
+	}
+	return nil
+}
+
+func lockHandler(c *cli.Context) error {
+	m, err := loadManifest(c.String("file"))
+	if err != nil {
+		return err
+	}
+	lock, err := lockTools(m, c.Int("jobs"), c.Bool("dry-run"))
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REPO\tTAG\tPLATFORM\tASSET")
+	for _, locked := range lock.Tools {
+		for _, p := range lock.Platforms {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", locked.Repo, locked.Tag, p, locked.Assets[p].Name)
+		}
+	}
+	w.Flush()
+
+	if c.Bool("dry-run") {
+		return nil
+	}
+	if err := lock.save(); err != nil {
+		return err
+	}
+	log.Infof("wrote %s", lock.path)
+	return nil
+}
+
+// switchVersion activates the given version of the given install, which is
+// already in the store, and saves the state
+func switchVersion(c *cli.Context, state *installState, rec *installRecord, tag string) error {
+	v := rec.version(tag)
+	if v == nil {
+		return fmt.Errorf("%s %s is no longer in the store", rec.Repo, tag)
+	}
+	if c.Bool("dry-run") {
+		plan := newPlanWriter()
+		links, targets := storeLinks(rec, v)
+		for i, link := range links {
+			plan.add("link", link, "=> "+targets[i])
+		}
+		plan.Flush()
+		return nil
+	}
+
+	log.Infof("switching %s from %s to %s", rec.Repo, rec.Tag, tag)
+	if err := activate(rec, tag, installOptions{overwrite: c.Bool("overwrite")}); err != nil {
+		return err
+	}
+	// the version was chosen deliberately, upgrades leave it alone
+	rec.Held = true
+	return state.save()
+}
+
+// listArchive prints the contents of the archive at the given path ("-" for
+// stdin), along with whether the given keep rules select each entry, as a
+// table or as json
+func listArchive(archivePath string, keepStrings []string, opts extract.Options, asJSON bool) error {
+	var entries []extract.ListEntry
+	var err error
+	if archivePath == "-" {
+		entries, err = extract.ListReader(os.Stdin, archivePath, keepStrings, opts)
+	} else {
+		entries, err = extract.ListFile(archivePath, keepStrings, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list the archive \"%s\"; error: %s", archivePath, err)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEEP\tTYPE\tMODE\tSIZE\tMTIME\tPATH")
+	for _, e := range entries {
+		keep := "-"
+		if e.Selected {
+			keep = "yes"
+		}
+		size := "-"
+		if e.Size >= 0 {
+			size = strconv.FormatInt(e.Size, 10)
+		}
+		mtime := "-"
+		if !e.ModTime.IsZero() {
+			mtime = e.ModTime.Local().Format("2006-01-02 15:04:05")
+		}
+		path := e.Path
+		if e.Linkname != "" {
+			path += " -> " + e.Linkname
+		}
+		if e.Output != "" && e.Output != e.Path {
+			path += " => " + e.Output
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", keep, e.Type, e.Mode, size, mtime, path)
+	}
+	return w.Flush()
+}
+
+// downloadDryRun reports what the download command would do with the given
+// asset without changing any files. The asset is only fetched if its
+// contents are needed for extraction or listing, for listing it's saved to a
+// temporary directory.
+func downloadDryRun(c *cli.Context, assetURL string, outputpath string, mode os.FileMode, opts extract.Options) error {
+	plan := newPlanWriter()
+	defer plan.Flush()
+
+	if c.Bool("extract") {
+		body, err := util.OpenURL(assetURL)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+
+		result, err := extract.ExtractReader(body, outputpath, mode, c.StringSlice("keep"), opts)
+		if result != nil {
+			result.LogSummary()
+		}
+		plan.addResult(result)
+		if err != nil {
+			return fmt.Errorf("failed to extract the archive \"%s\"; error: %s", outputpath, err)
+		}
+		return nil
+	}
+
+	if outputpath == "-" {
+		plan.add("write", "-", "download "+assetURL+" to stdout")
+		return nil
+	}
+	action, err := util.PlanFile(outputpath, false, c.Bool("overwrite"))
+	if err != nil {
+		plan.add("fail", outputpath, err.Error())
+		return err
+	}
+	plan.add(action, outputpath, "download "+assetURL)
+	if c.Bool("remove-archive") {
+		plan.add("remove", outputpath, "")
+	}
+	if !c.Bool("list-contents") {
+		return nil
+	}
+
+	tmpDir, err := os.MkdirTemp("", "ghlatest-dry-run-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	tmpPath := filepath.Join(tmpDir, filepath.Base(outputpath))
+	log.Debugf("downloading %s to %s for the dry run", assetURL, tmpPath)
+	if err := util.DownloadFile(assetURL, tmpPath, 0600, false); err != nil {
+		return err
+	}
+	opts.Name = outputpath
+
+	plan.Flush()
+	return listArchive(tmpPath, c.StringSlice("keep"), opts, c.Bool("json"))
+}
+
+// planWriter prints the actions a dry run would take as a table
+type planWriter struct {
+	*tabwriter.Writer
+}
+
+// newPlanWriter returns a planWriter which prints to stdout
+func newPlanWriter() planWriter {
+	return planWriter{tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)}
+}
+
+// add prints an action which would be taken on the given path
+func (p planWriter) add(action string, path string, detail string) {
+	fmt.Fprintf(p, "%s\t%s\t%s\n", action, path, detail)
+}
+
+// addResult prints the actions from the given dry run extraction Result
+func (p planWriter) addResult(res *extract.Result) {
+	if res == nil {
+		return
+	}
+	for _, e := range res.Extracted {
+		detail := ""
+		if e.Path != util.NormalizeFilePath(e.Name) {
+			detail = "from " + e.Name
+		}
+		p.add(e.Action, e.Path, detail)
+	}
+	for _, e := range res.Failed {
+		p.add("fail", e.Path, e.Reason)
+	}
+}
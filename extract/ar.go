@@ -0,0 +1,145 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// arMagic is the global header found at the start of ar archives
+const arMagic = "!<arch>\n"
+
+// arHeader describes a member of an ar archive
+type arHeader struct {
+	Name    string
+	ModTime time.Time
+	Uid     int
+	Gid     int
+	Mode    int64
+	Size    int64
+}
+
+// arReader provides sequential access to the members of an ar archive in
+// either the GNU/SysV or BSD variants. Member names from the GNU long name
+// table and BSD "#1/" extended names are resolved, the symbol tables are
+// skipped.
+type arReader struct {
+	r         io.Reader
+	remaining int64  // unread bytes in the current member
+	pad       int64  // padding byte following the current member
+	longNames []byte // GNU long name table
+}
+
+// newArReader checks the ar global header and returns a reader for the
+// archive members
+func newArReader(r io.Reader) (*arReader, error) {
+	magic := make([]byte, len(arMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != arMagic {
+		return nil, fmt.Errorf("not an ar archive")
+	}
+	return &arReader{r: r}, nil
+}
+
+// Next advances to the next member of the archive, io.EOF is returned at the
+// end of the archive
+func (ar *arReader) Next() (*arHeader, error) {
+	for {
+		if _, err := io.CopyN(io.Discard, ar.r, ar.remaining+ar.pad); err != nil {
+			return nil, err
+		}
+		ar.remaining, ar.pad = 0, 0
+
+		raw := make([]byte, 60)
+		if _, err := io.ReadFull(ar.r, raw); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return nil, fmt.Errorf("truncated ar member header")
+			}
+			return nil, err
+		}
+		if string(raw[58:60]) != "`\n" {
+			return nil, fmt.Errorf("invalid ar member header")
+		}
+
+		hdr := &arHeader{}
+		field := func(start, end int) string {
+			return strings.TrimSpace(string(raw[start:end]))
+		}
+		var err error
+		if hdr.Size, err = strconv.ParseInt(field(48, 58), 10, 64); err != nil || hdr.Size < 0 {
+			return nil, fmt.Errorf("invalid ar member size %q", field(48, 58))
+		}
+		mtime, _ := strconv.ParseInt(field(16, 28), 10, 64)
+		hdr.ModTime = time.Unix(mtime, 0)
+		hdr.Uid, _ = strconv.Atoi(field(28, 34))
+		hdr.Gid, _ = strconv.Atoi(field(34, 40))
+		hdr.Mode, _ = strconv.ParseInt(field(40, 48), 8, 64)
+		ar.remaining = hdr.Size
+		ar.pad = hdr.Size % 2
+
+		name := field(0, 16)
+		switch {
+		case name == "/" || name == "/SYM64/" || strings.HasPrefix(name, "__.SYMDEF"):
+			// symbol tables
+			continue
+		case name == "//":
+			// GNU long name table
+			ar.longNames = make([]byte, hdr.Size)
+			if _, err := io.ReadFull(ar.r, ar.longNames); err != nil {
+				return nil, err
+			}
+			ar.remaining = 0
+			continue
+		case strings.HasPrefix(name, "#1/"):
+			// BSD extended name, stored at the start of the member data
+			nameLen, err := strconv.ParseInt(name[3:], 10, 64)
+			if err != nil || nameLen < 0 || nameLen > hdr.Size {
+				return nil, fmt.Errorf("invalid ar extended name length %q", name)
+			}
+			extName := make([]byte, nameLen)
+			if _, err := io.ReadFull(ar.r, extName); err != nil {
+				return nil, err
+			}
+			name = string(bytes.TrimRight(extName, "\x00"))
+			hdr.Size -= nameLen
+			ar.remaining = hdr.Size
+		case len(name) > 1 && name[0] == '/':
+			// GNU long name, an offset into the long name table
+			offset, err := strconv.Atoi(name[1:])
+			if err != nil || offset < 0 || offset >= len(ar.longNames) {
+				return nil, fmt.Errorf("invalid ar long name reference %q", name)
+			}
+			end := bytes.Index(ar.longNames[offset:], []byte("/\n"))
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated ar long name at offset %d", offset)
+			}
+			name = string(ar.longNames[offset : offset+end])
+		default:
+			// GNU names are terminated with a slash
+			name = strings.TrimSuffix(name, "/")
+		}
+		hdr.Name = name
+		return hdr, nil
+	}
+}
+
+// Read reads from the current member of the archive
+func (ar *arReader) Read(p []byte) (int, error) {
+	if ar.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > ar.remaining {
+		p = p[:ar.remaining]
+	}
+	n, err := ar.r.Read(p)
+	ar.remaining -= int64(n)
+	if err == io.EOF && ar.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
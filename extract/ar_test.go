@@ -0,0 +1,86 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// arMember is a member of an ar archive built by arBytes, its name is
+// written to the header as it is
+type arMember struct {
+	name string
+	data string
+}
+
+// arBytes returns an ar archive of the given members
+func arBytes(members []arMember) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(arMagic)
+	for _, m := range members {
+		fmt.Fprintf(&buf, "%-16s%-12d%-6d%-6d%-8o%-10d`\n", m.name, 1700000000, 0, 0, 0644, len(m.data))
+		buf.WriteString(m.data)
+		if len(m.data)%2 == 1 {
+			buf.WriteString("\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestWalkAr(t *testing.T) {
+	longNames := "a-rather-long-member-name.txt/\nanother-long-member-name.o/\n"
+	tests := []struct {
+		variant string
+		members []arMember
+		want    []walkedEntry
+	}{
+		{
+			variant: "gnu",
+			members: []arMember{
+				{"/", "\x00\x00\x00\x00"}, // symbol table
+				{"//", longNames},
+				{"/0", "first"},
+				{fmt.Sprintf("/%d", len("a-rather-long-member-name.txt/\n")), "second"},
+				{"short.o/", "odd"},
+			},
+			want: []walkedEntry{
+				{name: "a-rather-long-member-name.txt", typ: typeRegular, data: "first"},
+				{name: "another-long-member-name.o", typ: typeRegular, data: "second"},
+				{name: "short.o", typ: typeRegular, data: "odd"},
+			},
+		},
+		{
+			variant: "bsd",
+			members: []arMember{
+				{"__.SYMDEF", "\x00\x00\x00\x00"},
+				{"#1/28", "a-rather-long-member-name.o\x00contents"},
+				{"short.o", "odd"},
+			},
+			want: []walkedEntry{
+				{name: "a-rather-long-member-name.o", typ: typeRegular, data: "contents"},
+				{name: "short.o", typ: typeRegular, data: "odd"},
+			},
+		},
+	}
+	for _, tc := range tests {
+		archive := arBytes(tc.members)
+		got := walkAll(t, func(fn walkFunc) error { return walkAr(bytes.NewReader(archive), fn) })
+		if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("%s:\n got %v\nwant %v", tc.variant, got, tc.want)
+		}
+	}
+}
+
+func TestWalkArInvalid(t *testing.T) {
+	tests := map[string][]byte{
+		"bad magic":          []byte("!<arch>x"),
+		"truncated header":   arBytes([]arMember{{"tool/", "tool"}})[:40],
+		"unknown long name":  arBytes([]arMember{{"//", "tool/\n"}, {"/99", "tool"}}),
+		"missing name table": arBytes([]arMember{{"/0", "tool"}}),
+	}
+	for name, archive := range tests {
+		if err := walkAr(bytes.NewReader(archive), func(e *entry) error { return nil }); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -42,12 +42,24 @@ func OpenArchive(filePath string) (*Archive, error) {
 func (a *Archive) Close() {
 	if a.StreamHandle != nil {
 		if err := a.StreamHandle.Close(); err != nil {
-			log.Fatalln(err)
+			log.Errorf("closing the decompression stream for %s failed; error: %s", a.Path, err)
 		}
 	}
 	if a.FileHandle != nil {
 		if err := a.FileHandle.Close(); err != nil {
-			log.Fatalln(err)
+			log.Errorf("closing %s failed; error: %s", a.Path, err)
 		}
 	}
 }
+
+// stream returns the reader for sequential formats: the decompression reader
+// if one has been set up, otherwise the archive file itself
+func (a *Archive) stream() io.Reader {
+	if a.StreamHandle != nil {
+		// StreamHandle would be available if we're decompressing as well
+		log.Debug("selected StreamHandle")
+		return a.StreamHandle
+	}
+	log.Debug("selected FileHandle")
+	return a.FileHandle
+}
@@ -1,69 +1,43 @@
 package extract
 
 import (
+	"io"
+
 	"github.com/backplane/ghlatest/util"
 	"github.com/bodgit/sevenzip"
-	log "github.com/sirupsen/logrus"
 )
 
 // Un7z extracts the Archive's contents into the given output directory using
-// the a sevenzip file reader. If there are any filters in the given FilterSet then
-// files are only extracted if they match one of the given filters. If the
-// files to be created conflict with existing files in the outputDir then
-// extraction will stop unless the overwrite argument is set to true.
-func (a *Archive) Un7z(outputDir string, filters util.FilterSet, overwrite bool) []string {
-	// https://pkg.go.dev/github.com/bodgit/sevenzip@v1.4.0
-
-	// fixme: outputDir is not currently implemented!
+// the a sevenzip file reader. If there are any rules in the given KeepSet then
+// files are only extracted if they match one of the given rules, which may also
+// rename them. If the files to be created conflict with existing files in the
+// outputDir then they are treated as failures unless opts.Overwrite is set to
+// true; opts.OnError determines whether failures stop the extraction.
+func (a *Archive) Un7z(outputDir string, keep util.KeepSet, opts Options) (*Result, error) {
+	x := newExtractor(outputDir, keep, opts)
+	return x.finish(walk7z(a.FileHandle, a.FileStats.Size(), x.extract))
+}
 
-	r, err := sevenzip.NewReader(a.FileHandle, a.FileStats.Size())
+// walk7z calls fn for each entry of the given 7z file
+func walk7z(r io.ReaderAt, size int64, fn walkFunc) error {
+	// https://pkg.go.dev/github.com/bodgit/sevenzip@v1.4.0
+	sr, err := sevenzip.NewReader(r, size)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	extractedFiles := make([]string, 0)
-	var filtering bool = len(filters) > 0
-
-	for _, f := range r.File {
-		filePath := util.NormalizeFilePath(f.Name)
-		mode := f.Mode().Perm()
-		if filtering {
-			var include_file bool = false
-			for _, filter := range filters {
-				if filter.MatchString(filePath) {
-					include_file = true
-					break
-				}
-			}
-			if !include_file {
-				log.Debugf("Skipping %s", filePath)
-				continue
-			}
+	for _, f := range sr.File {
+		e := &entry{
+			Name: f.Name,
+			Mode: f.Mode().Perm(),
+			Size: int64(f.UncompressedSize),
+			Meta: entryMetadata{ModTime: f.Modified, AccessTime: f.Accessed},
+			Open: f.Open,
 		}
-
-		if f.FileInfo().IsDir() {
-			err := util.NewDirectory(filePath, mode)
-			if err != nil {
-				log.Errorf("skipping any remaining files in archive")
-				break
-			}
-			extractedFiles = append(extractedFiles, filePath)
-			continue
-		}
-
-		srcContents, err := f.Open()
-		if err != nil {
-			log.Fatalf("Opening source contents of %s failed; error: %s", filePath, err)
+		e.Type, e.Reason = fileModeType(f.Mode())
+		if err := fn(e); err != nil {
+			return err
 		}
-		defer srcContents.Close()
-
-		_, err = util.NewFileFromSource(filePath, mode, overwrite, srcContents)
-		if err != nil {
-			log.Errorf("skipping any remaining files in archive")
-			break
-		}
-
-		extractedFiles = append(extractedFiles, filePath)
 	}
-	return extractedFiles
+	return nil
 }
@@ -0,0 +1,49 @@
+package extract
+
+import (
+	"io"
+	"io/fs"
+
+	"github.com/backplane/ghlatest/util"
+)
+
+// Unar extracts the Archive's contents into the given output directory using
+// an ar file reader. If there are any rules in the given KeepSet then files
+// are only extracted if they match one of the given rules, which may also
+// rename them. If the files to be created conflict with existing files in the
+// outputDir then they are treated as failures unless opts.Overwrite is set to
+// true; opts.OnError determines whether failures stop the extraction.
+func (a *Archive) Unar(outputDir string, keep util.KeepSet, opts Options) (*Result, error) {
+	x := newExtractor(outputDir, keep, opts)
+	return x.finish(walkAr(a.stream(), x.extract))
+}
+
+// walkAr calls fn for each member of the given ar stream
+func walkAr(r io.Reader, fn walkFunc) error {
+	ar, err := newArReader(r)
+	if err != nil {
+		return err
+	}
+
+	for {
+		f, err := ar.Next()
+		if err == io.EOF {
+			return nil // End of archive
+		}
+		if err != nil {
+			return err
+		}
+
+		e := &entry{
+			Name: f.Name,
+			Type: typeRegular,
+			Mode: fs.FileMode(f.Mode).Perm(),
+			Size: f.Size,
+			Meta: entryMetadata{ModTime: f.ModTime, HasOwner: true, Uid: f.Uid, Gid: f.Gid},
+			Open: streamOpener(ar),
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+}
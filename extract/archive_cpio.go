@@ -0,0 +1,122 @@
+package extract
+
+import (
+	"bytes"
+	"io"
+	"sort"
+
+	"github.com/backplane/ghlatest/util"
+)
+
+// Uncpio extracts the Archive's contents into the given output directory
+// using a cpio file reader. If there are any rules in the given KeepSet then
+// files are only extracted if they match one of the given rules, which may
+// also rename them. If the files to be created conflict with existing files in
+// the outputDir then they are treated as failures unless opts.Overwrite is set
+// to true; opts.OnError determines whether failures stop the extraction.
+func (a *Archive) Uncpio(outputDir string, keep util.KeepSet, opts Options) (*Result, error) {
+	x := newExtractor(outputDir, keep, opts)
+	return x.finish(walkCpio(a.stream(), x.extract))
+}
+
+// walkCpio calls fn for each entry of the given cpio stream
+func walkCpio(r io.Reader, fn walkFunc) error {
+	cr := newCpioReader(r)
+
+	// the data for hardlinked files is stored with only one of the links
+	// (the last one in the newc format), the others are empty. Links which
+	// precede their data are held back and reported as hardlinks to it.
+	pendingLinks := make(map[int64][]*cpioHeader)
+	dataNames := make(map[int64]string)
+
+	for {
+		f, err := cr.Next()
+		if err == io.EOF {
+			break // End of archive
+		}
+		if err != nil {
+			return err
+		}
+
+		e := cpioEntry(f)
+		e.Open = streamOpener(cr)
+		switch f.Type() {
+		case cpioTypeReg:
+			if f.Nlink > 1 && f.Size == 0 {
+				if dataName, ok := dataNames[f.Ino]; ok {
+					e.Type = typeHardlink
+					e.Linkname = dataName
+					break
+				}
+				pendingLinks[f.Ino] = append(pendingLinks[f.Ino], f)
+				continue
+			}
+			e.Type = typeRegular
+		case cpioTypeSymlink:
+			// the link target is stored as the entry's contents
+			e.Type = typeSymlink
+		case cpioTypeDir:
+			e.Type = typeDir
+		case cpioTypeFifo:
+			e.Type = typeFifo
+		case cpioTypeChar:
+			e.Type = typeChar
+		case cpioTypeBlock:
+			e.Type = typeBlock
+		case cpioTypeSocket:
+			e.Type = typeUnsupported
+			e.Reason = "sockets can't be extracted"
+		default:
+			e.Type = typeUnsupported
+			e.Reason = "unknown cpio entry type"
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+
+		if e.Type == typeRegular && f.Nlink > 1 {
+			dataNames[f.Ino] = f.Name
+			for _, link := range pendingLinks[f.Ino] {
+				le := cpioEntry(link)
+				le.Type = typeHardlink
+				le.Linkname = f.Name
+				if err := fn(le); err != nil {
+					return err
+				}
+			}
+			delete(pendingLinks, f.Ino)
+		}
+	}
+
+	// hardlinks whose contents never arrived are empty files
+	inodes := make([]int64, 0, len(pendingLinks))
+	for ino := range pendingLinks {
+		inodes = append(inodes, ino)
+	}
+	sort.Slice(inodes, func(i, j int) bool { return inodes[i] < inodes[j] })
+	for _, ino := range inodes {
+		for _, link := range pendingLinks[ino] {
+			le := cpioEntry(link)
+			le.Type = typeRegular
+			le.Open = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(nil)), nil
+			}
+			if err := fn(le); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// cpioEntry returns an entry with the attributes from the given cpio header
+func cpioEntry(f *cpioHeader) *entry {
+	return &entry{
+		Name:     f.Name,
+		Mode:     f.Perm(),
+		Size:     f.Size,
+		DevMajor: f.RdevMajor,
+		DevMinor: f.RdevMinor,
+		Meta:     entryMetadata{ModTime: f.ModTime, HasOwner: true, Uid: f.Uid, Gid: f.Gid},
+	}
+}
@@ -0,0 +1,57 @@
+package extract
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/backplane/ghlatest/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// Undeb extracts the files installed by the Debian package in the Archive into
+// the given output directory. A .deb file is an ar archive, the installed
+// files are in its (optionally compressed) "data.tar" member. If there are any
+// rules in the given KeepSet then files are only extracted if they match one
+// of the given rules, which may also rename them. If the files to be created
+// conflict with existing files in the outputDir then they are treated as
+// failures unless opts.Overwrite is set to true; opts.OnError determines
+// whether failures stop the extraction.
+func (a *Archive) Undeb(outputDir string, keep util.KeepSet, opts Options) (*Result, error) {
+	x := newExtractor(outputDir, keep, opts)
+	return x.finish(walkDeb(a.FileHandle, x.extract))
+}
+
+// walkDeb calls fn for each entry in the data.tar member of the given deb
+// package stream
+func walkDeb(r io.Reader, fn walkFunc) error {
+	// see: https://manpages.debian.org/deb.5
+	ar, err := newArReader(r)
+	if err != nil {
+		return err
+	}
+
+	for {
+		member, err := ar.Next()
+		if err == io.EOF {
+			return fmt.Errorf("deb package has no data.tar member")
+		}
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(member.Name, "data.tar") {
+			log.Debugf("skipping deb member %s", member.Name)
+			continue
+		}
+
+		stream, compression, err := decompressStream(ar)
+		if err != nil {
+			return fmt.Errorf("decompressing deb member %s failed; error: %s", member.Name, err)
+		}
+		defer stream.Close()
+		if compression != "" {
+			log.Infof("decompressing (%s) deb member %s", compression, member.Name)
+		}
+		return walkTar(stream, fn)
+	}
+}
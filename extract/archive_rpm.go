@@ -0,0 +1,81 @@
+package extract
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/backplane/ghlatest/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// rpm file structure constants
+const (
+	rpmLeadSize    = 96
+	rpmLeadMagic   = "\xed\xab\xee\xdb"
+	rpmHeaderMagic = "\x8e\xad\xe8\x01"
+)
+
+// skipRPMHeader reads past an rpm header structure (as used for both the
+// signature and the main header) and returns the number of bytes it occupied
+func skipRPMHeader(r io.Reader) (int64, error) {
+	intro := make([]byte, 16)
+	if _, err := io.ReadFull(r, intro); err != nil {
+		return 0, fmt.Errorf("truncated rpm header")
+	}
+	if string(intro[0:4]) != rpmHeaderMagic {
+		return 0, fmt.Errorf("invalid rpm header magic")
+	}
+	indexCount := int64(binary.BigEndian.Uint32(intro[8:12]))
+	dataSize := int64(binary.BigEndian.Uint32(intro[12:16]))
+	size := 16*indexCount + dataSize
+	if _, err := io.CopyN(io.Discard, r, size); err != nil {
+		return 0, fmt.Errorf("truncated rpm header")
+	}
+	return 16 + size, nil
+}
+
+// Unrpm extracts the files installed by the RPM package in the Archive into
+// the given output directory. The package's lead and headers are skipped and
+// its (optionally compressed) cpio payload is extracted. If there are any
+// rules in the given KeepSet then files are only extracted if they match one
+// of the given rules, which may also rename them. If the files to be created
+// conflict with existing files in the outputDir then they are treated as
+// failures unless opts.Overwrite is set to true; opts.OnError determines
+// whether failures stop the extraction.
+func (a *Archive) Unrpm(outputDir string, keep util.KeepSet, opts Options) (*Result, error) {
+	x := newExtractor(outputDir, keep, opts)
+	return x.finish(walkRpm(a.FileHandle, x.extract))
+}
+
+// walkRpm calls fn for each entry in the payload of the given rpm package
+// stream
+func walkRpm(r io.Reader, fn walkFunc) error {
+	// see: https://rpm-software-management.github.io/rpm/manual/format.html
+	lead := make([]byte, rpmLeadSize)
+	if _, err := io.ReadFull(r, lead); err != nil || string(lead[0:4]) != rpmLeadMagic {
+		return fmt.Errorf("invalid rpm lead")
+	}
+
+	// the signature header is padded to a multiple of 8 bytes
+	sigSize, err := skipRPMHeader(r)
+	if err == nil {
+		_, err = io.CopyN(io.Discard, r, padding(sigSize, 8))
+	}
+	if err != nil {
+		return fmt.Errorf("reading rpm signature failed; error: %s", err)
+	}
+	if _, err := skipRPMHeader(r); err != nil {
+		return fmt.Errorf("reading rpm header failed; error: %s", err)
+	}
+
+	stream, compression, err := decompressStream(r)
+	if err != nil {
+		return fmt.Errorf("decompressing rpm payload failed; error: %s", err)
+	}
+	defer stream.Close()
+	if compression != "" {
+		log.Infof("decompressing (%s) rpm payload", compression)
+	}
+	return walkCpio(stream, fn)
+}
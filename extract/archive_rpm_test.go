@@ -0,0 +1,74 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+// rpmHeaderBytes returns an rpm header structure with the given number of
+// index entries and data size, whose contents are filler
+func rpmHeaderBytes(indexCount int, dataSize int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(rpmHeaderMagic)
+	buf.Write(make([]byte, 4)) // reserved
+	binary.Write(&buf, binary.BigEndian, uint32(indexCount))
+	binary.Write(&buf, binary.BigEndian, uint32(dataSize))
+	buf.Write(bytes.Repeat([]byte{0xaa}, 16*indexCount+dataSize))
+	return buf.Bytes()
+}
+
+// rpmBytes returns an rpm package with the given payload, whose signature
+// header has the given data size
+func rpmBytes(sigDataSize int, payload []byte) []byte {
+	var buf bytes.Buffer
+	lead := make([]byte, rpmLeadSize)
+	copy(lead, rpmLeadMagic)
+	buf.Write(lead)
+	sig := rpmHeaderBytes(1, sigDataSize)
+	buf.Write(sig)
+	buf.Write(make([]byte, padding(int64(len(sig)), 8)))
+	buf.Write(rpmHeaderBytes(3, 21))
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+func TestWalkRpm(t *testing.T) {
+	payload := cpioBytes(false, []cpioTestEntry{
+		{name: "./usr/bin/tool", ino: 1, mode: 0100755, nlink: 1, data: "tool\n"},
+	})
+	want := []walkedEntry{{name: "./usr/bin/tool", typ: typeRegular, data: "tool\n"}}
+	tests := []struct {
+		name string
+		pkg  []byte
+	}{
+		// the signature header is padded to 8 bytes unless it's aligned
+		{"aligned signature", rpmBytes(8, payload)},
+		{"padded signature", rpmBytes(5, payload)},
+		{"compressed payload", rpmBytes(5, gzipBytes(t, payload))},
+	}
+	for _, tc := range tests {
+		got := walkAll(t, func(fn walkFunc) error { return walkRpm(bytes.NewReader(tc.pkg), fn) })
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s:\n got %v\nwant %v", tc.name, got, want)
+		}
+	}
+}
+
+func TestWalkRpmInvalid(t *testing.T) {
+	valid := rpmBytes(5, cpioBytes(false, nil))
+	badHeader := append([]byte{}, valid...)
+	badHeader[rpmLeadSize] = 0
+	tests := map[string][]byte{
+		"bad lead":         append([]byte("\xed\xab\xee\xdc"), valid[4:]...),
+		"bad header magic": badHeader,
+		"truncated lead":   valid[:50],
+		"truncated header": valid[:rpmLeadSize+20],
+	}
+	for name, pkg := range tests {
+		if err := walkRpm(bytes.NewReader(pkg), func(e *entry) error { return nil }); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -3,100 +3,94 @@ package extract
 import (
 	"archive/tar"
 	"io"
-	"os"
+	"strings"
 
 	"github.com/backplane/ghlatest/util"
 	log "github.com/sirupsen/logrus"
 )
 
 // Untar extracts the Archive's contents into the given output directory using
-// a tar file reader. If there are any filters in the given FilterSet then files
-// are only extracted if they match one of the given filters. If the files to
-// be created conflict with existing files in the outputDir then extraction
-// will stop unless the overwrite argument is set to true.
-func (a *Archive) Untar(outputDir string, filters util.FilterSet, overwrite bool) []string {
+// a tar file reader. If there are any rules in the given KeepSet then files are
+// only extracted if they match one of the given rules, which may also rename
+// them. If the files to be created conflict with existing files in the
+// outputDir then they are treated as failures unless opts.Overwrite is set to
+// true; opts.OnError determines whether failures stop the extraction.
+func (a *Archive) Untar(outputDir string, keep util.KeepSet, opts Options) (*Result, error) {
+	x := newExtractor(outputDir, keep, opts)
+	return x.finish(walkTar(a.stream(), x.extract))
+}
+
+// walkTar calls fn for each entry of the given tar stream
+func walkTar(r io.Reader, fn walkFunc) error {
 	// see: https://pkg.go.dev/archive/tar#pkg-overview
 	// Open and iterate through the files in the archive.
-
-	// fixme: outputDir is not currently implemented!
-
-	var tr *tar.Reader
-	if a.StreamHandle != nil {
-		// StreamHandle would be available if we're decompressing as well
-		log.Debug("untar selected StreamHandle")
-		tr = tar.NewReader(a.StreamHandle)
-	} else {
-		log.Debug("untar selected FileHandle")
-		tr = tar.NewReader(a.FileHandle)
-	}
-
-	extractedFiles := make([]string, 0)
-	var filtering bool = len(filters) > 0
+	tr := tar.NewReader(r)
 
 	for {
 		f, err := tr.Next()
 		if err == io.EOF {
-			break // End of archive
+			return nil // End of archive
 		}
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 
-		filePath := util.NormalizeFilePath(f.Name)
-		if filtering {
-			var include_file bool = false
-			for _, filter := range filters {
-				if filter.MatchString(filePath) {
-					include_file = true
-					break
-				}
-			}
-			if !include_file {
-				log.Debugf("Skipping %s", filePath)
-				continue
-			}
+		e := &entry{
+			Name:     f.Name,
+			Mode:     f.FileInfo().Mode().Perm(),
+			Size:     f.Size,
+			Linkname: f.Linkname,
+			DevMajor: f.Devmajor,
+			DevMinor: f.Devminor,
+			Meta: entryMetadata{
+				ModTime:    f.ModTime,
+				AccessTime: f.AccessTime,
+				HasOwner:   true,
+				Uid:        f.Uid,
+				Gid:        f.Gid,
+				Xattrs:     paxXattrs(f.PAXRecords),
+			},
+			Open: streamOpener(tr),
 		}
-
-		permissions := f.FileInfo().Mode().Perm()
 		switch f.Typeflag {
-		case tar.TypeReg:
-			_, err = util.NewFileFromSource(filePath, permissions, overwrite, tr)
-			if err != nil {
-				log.Errorf("%s: extracting file failed; error: %s; skipping any remaining files in archive", filePath, err)
-				goto CONTINUE_OUTER
-			}
+		case tar.TypeReg, tar.TypeGNUSparse:
+			e.Type = typeRegular
+			e.Sparse = isSparse(f)
 		case tar.TypeLink:
-			err = os.Link(f.Linkname, filePath)
-			if err != nil {
-				log.Errorf("%s: creating symlink failed; error: %s; skipping any remaining files in archive", filePath, err)
-				goto CONTINUE_OUTER
-			}
+			e.Type = typeHardlink
 		case tar.TypeSymlink:
-			err = os.Symlink(f.Linkname, filePath)
-			if err != nil {
-				log.Errorf("%s: creating symlink failed; error: %s; skipping any remaining files in archive", filePath, err)
-				goto CONTINUE_OUTER
-			}
+			e.Type = typeSymlink
 		case tar.TypeDir:
-			err := util.NewDirectory(filePath, permissions)
-			if err != nil {
-				log.Errorf("%s: mkdir failed; error: %s; skipping any remaining files in archive", filePath, err)
-				goto CONTINUE_OUTER
-			}
+			e.Type = typeDir
 		case tar.TypeFifo:
-			log.Errorf("%s: mkfifo skipped; extracting FIFOs is not currently supported", filePath)
-			continue
+			e.Type = typeFifo
+		case tar.TypeChar:
+			e.Type = typeChar
+		case tar.TypeBlock:
+			e.Type = typeBlock
 		case tar.TypeXGlobalHeader:
-			log.Debugf("%s: skipping pax global header file", filePath)
+			log.Debugf("%s: skipping pax global header file", f.Name)
 			continue
-		case tar.TypeGNUSparse:
-			log.Debugf("%s: GNU Sparse files are not supported", filePath)
-			goto CONTINUE_OUTER
 		default:
-			log.Errorf("%s: unknown type %d; skipping any remaining files in archive", filePath, f.Typeflag)
+			e.Type = typeUnsupported
+			e.Reason = "unknown tar entry type " + string(f.Typeflag)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+}
+
+// isSparse reports whether the given header describes a sparse file, in
+// either the old GNU format or one of the GNU PAX formats
+func isSparse(hdr *tar.Header) bool {
+	if hdr.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for key := range hdr.PAXRecords {
+		if strings.HasPrefix(key, "GNU.sparse.") {
+			return true
 		}
-		extractedFiles = append(extractedFiles, filePath)
 	}
-CONTINUE_OUTER:
-	return extractedFiles
+	return false
 }
@@ -2,80 +2,65 @@ package extract
 
 import (
 	"archive/zip"
-	"os"
+	"fmt"
+	"io"
+	"io/fs"
 
 	"github.com/backplane/ghlatest/util"
-	log "github.com/sirupsen/logrus"
 )
 
 // Unzip extracts the Archive's contents into the given output directory using
-// the a zip file reader. If there are any filters in the given FilterSet then
-// files are only extracted if they match one of the given filters. If the
-// files to be created conflict with existing files in the outputDir then
-// extraction will stop unless the overwrite argument is set to true.
-func (a *Archive) Unzip(outputDir string, filters util.FilterSet, overwrite bool) []string {
+// the a zip file reader. If there are any rules in the given KeepSet then files
+// are only extracted if they match one of the given rules, which may also
+// rename them. If the files to be created conflict with existing files in the
+// outputDir then they are treated as failures unless opts.Overwrite is set to
+// true; opts.OnError determines whether failures stop the extraction.
+func (a *Archive) Unzip(outputDir string, keep util.KeepSet, opts Options) (*Result, error) {
+	x := newExtractor(outputDir, keep, opts)
+	return x.finish(walkZip(a.FileHandle, a.FileStats.Size(), x.extract))
+}
+
+// walkZip calls fn for each entry of the given zip file
+func walkZip(r io.ReaderAt, size int64, fn walkFunc) error {
 	// https://pkg.go.dev/archive/zip@go1.20.1#example-Reader
 	// Open a zip archive for reading.
-
-	// fixme: outputDir is not currently implemented!
-
-	r, err := zip.NewReader(a.FileHandle, a.FileStats.Size())
+	zr, err := zip.NewReader(r, size)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	extractedFiles := make([]string, 0)
-	var filtering bool = len(filters) > 0
-
-	for _, f := range r.File {
-		filePath := util.NormalizeFilePath(f.Name)
-		if filtering {
-			var include_file bool = false
-			for _, filter := range filters {
-				if filter.MatchString(filePath) {
-					include_file = true
-					break
-				}
-			}
-			if !include_file {
-				log.Debugf("Skipping %s", filePath)
-				continue
-			}
+	for _, f := range zr.File {
+		info := f.FileInfo()
+		e := &entry{
+			Name: f.Name,
+			Mode: info.Mode().Perm(),
+			Size: info.Size(),
+			Meta: entryMetadata{ModTime: info.ModTime()},
+			Open: f.Open,
 		}
-
-		permissions := f.FileInfo().Mode().Perm()
-		fType := f.FileInfo().Mode().Type()
-		switch {
-		case fType.IsRegular():
-			contents, err := f.Open()
-			if err != nil {
-				log.Fatalf("%s: opening source contents of failed; error: %s", filePath, err)
-			}
-			defer contents.Close()
-
-			_, err = util.NewFileFromSource(filePath, permissions, overwrite, contents)
-			if err != nil {
-				log.Errorf("%s: extracting file failed; error: %s; skipping any remaining files in archive", filePath, err)
-				goto CONTINUE_OUTER
-			}
-		case fType.IsDir():
-			err := util.NewDirectory(filePath, permissions)
-			if err != nil {
-				log.Errorf("%s: mkdir failed; error: %s; skipping any remaining files in archive", filePath, err)
-				goto CONTINUE_OUTER
-			}
-		case fType&os.ModeSymlink != 0:
-			log.Errorf("%s: symlink skipped; extracting symlinks is not currently supported", filePath)
-			continue
-		case fType&os.ModeNamedPipe != 0:
-			log.Errorf("%s: mkfifo skipped; extracting FIFOs is not currently supported", filePath)
-			continue
-		default:
-			log.Errorf("%s: unknown type; skipping any remaining files in archive", filePath)
-			goto CONTINUE_OUTER
+		e.Type, e.Reason = fileModeType(info.Mode())
+		if err := fn(e); err != nil {
+			return err
 		}
-		extractedFiles = append(extractedFiles, filePath)
 	}
-CONTINUE_OUTER:
-	return extractedFiles
+	return nil
+}
+
+// fileModeType returns the entryType for the given fs.FileMode, as used by
+// the zip and 7z formats which store unix modes, along with a reason if the
+// type is unsupported
+func fileModeType(mode fs.FileMode) (entryType, string) {
+	switch fType := mode.Type(); {
+	case fType.IsRegular():
+		return typeRegular, ""
+	case fType.IsDir():
+		return typeDir, ""
+	case fType&fs.ModeSymlink != 0:
+		// the link target is stored as the entry's contents
+		return typeSymlink, ""
+	case fType&fs.ModeNamedPipe != 0:
+		return typeFifo, ""
+	default:
+		return typeUnsupported, fmt.Sprintf("unsupported file type %s", fType)
+	}
 }
@@ -0,0 +1,200 @@
+package extract
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// file type bits found in the mode field of cpio headers
+const (
+	cpioTypeMask    = 0170000
+	cpioTypeSocket  = 0140000
+	cpioTypeSymlink = 0120000
+	cpioTypeReg     = 0100000
+	cpioTypeBlock   = 0060000
+	cpioTypeDir     = 0040000
+	cpioTypeChar    = 0020000
+	cpioTypeFifo    = 0010000
+)
+
+// cpioTrailer is the name of the entry marking the end of a cpio archive
+const cpioTrailer = "TRAILER!!!"
+
+// cpioHeader describes an entry in a cpio archive
+type cpioHeader struct {
+	Name      string
+	Ino       int64
+	Mode      int64
+	Uid       int
+	Gid       int
+	Nlink     int64
+	ModTime   time.Time
+	Size      int64
+	RdevMajor int64
+	RdevMinor int64
+}
+
+// Type returns the file type bits of the entry's mode
+func (h *cpioHeader) Type() int64 {
+	return h.Mode & cpioTypeMask
+}
+
+// Perm returns the permission bits of the entry's mode
+func (h *cpioHeader) Perm() fs.FileMode {
+	return fs.FileMode(h.Mode & 0777)
+}
+
+// cpioReader provides sequential access to the entries of a cpio archive in
+// the "newc" (SVR4, with or without checksums) or "odc" (POSIX.1 portable)
+// formats
+type cpioReader struct {
+	r         io.Reader
+	remaining int64 // unread bytes in the current entry
+	pad       int64 // padding bytes following the current entry
+}
+
+// newCpioReader returns a reader for the entries of the given cpio archive
+func newCpioReader(r io.Reader) *cpioReader {
+	return &cpioReader{r: r}
+}
+
+// Next advances to the next entry of the archive, io.EOF is returned at the
+// end of the archive
+func (cr *cpioReader) Next() (*cpioHeader, error) {
+	if _, err := io.CopyN(io.Discard, cr.r, cr.remaining+cr.pad); err != nil {
+		return nil, err
+	}
+	cr.remaining, cr.pad = 0, 0
+
+	magic := make([]byte, 6)
+	if _, err := io.ReadFull(cr.r, magic); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("truncated cpio header")
+		}
+		return nil, err
+	}
+
+	var hdr *cpioHeader
+	var err error
+	switch string(magic) {
+	case "070701", "070702":
+		hdr, err = cr.readNewc()
+	case "070707":
+		hdr, err = cr.readOdc()
+	default:
+		return nil, fmt.Errorf("unsupported cpio header magic %q", magic)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if hdr.Name == cpioTrailer {
+		return nil, io.EOF
+	}
+	return hdr, nil
+}
+
+// readNewc reads the remainder of a "newc" header, in which each numeric
+// field is 8 hexadecimal digits and the name and data are padded to 4 bytes
+func (cr *cpioReader) readNewc() (*cpioHeader, error) {
+	raw := make([]byte, 13*8)
+	if _, err := io.ReadFull(cr.r, raw); err != nil {
+		return nil, fmt.Errorf("truncated cpio header")
+	}
+	fields := make([]int64, 13)
+	for i := range fields {
+		v, err := strconv.ParseUint(string(raw[i*8:(i+1)*8]), 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpio header field %q", raw[i*8:(i+1)*8])
+		}
+		fields[i] = int64(v)
+	}
+	hdr := &cpioHeader{
+		Ino:       fields[0],
+		Mode:      fields[1],
+		Uid:       int(fields[2]),
+		Gid:       int(fields[3]),
+		Nlink:     fields[4],
+		ModTime:   time.Unix(fields[5], 0),
+		Size:      fields[6],
+		RdevMajor: fields[9],
+		RdevMinor: fields[10],
+	}
+	nameSize := fields[11]
+
+	// the 110 byte header plus the name are padded to a multiple of 4 bytes
+	name := make([]byte, nameSize+padding(110+nameSize, 4))
+	if _, err := io.ReadFull(cr.r, name); err != nil {
+		return nil, fmt.Errorf("truncated cpio entry name")
+	}
+	hdr.Name = strings.TrimRight(string(name[:nameSize]), "\x00")
+	cr.remaining = hdr.Size
+	cr.pad = padding(hdr.Size, 4)
+	return hdr, nil
+}
+
+// readOdc reads the remainder of an "odc" header, in which the numeric fields
+// are octal digits and nothing is padded
+func (cr *cpioReader) readOdc() (*cpioHeader, error) {
+	raw := make([]byte, 70)
+	if _, err := io.ReadFull(cr.r, raw); err != nil {
+		return nil, fmt.Errorf("truncated cpio header")
+	}
+	// dev, ino, mode, uid, gid, nlink, rdev, mtime, namesize, filesize
+	widths := []int{6, 6, 6, 6, 6, 6, 6, 11, 6, 11}
+	fields := make([]int64, len(widths))
+	offset := 0
+	for i, width := range widths {
+		v, err := strconv.ParseUint(string(raw[offset:offset+width]), 8, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpio header field %q", raw[offset:offset+width])
+		}
+		fields[i] = int64(v)
+		offset += width
+	}
+	hdr := &cpioHeader{
+		Ino:     fields[1],
+		Mode:    fields[2],
+		Uid:     int(fields[3]),
+		Gid:     int(fields[4]),
+		Nlink:   fields[5],
+		ModTime: time.Unix(fields[7], 0),
+		Size:    fields[9],
+		// odc stores the device number as a single value
+		RdevMajor: fields[6] >> 8,
+		RdevMinor: fields[6] & 0xff,
+	}
+	nameSize := fields[8]
+
+	name := make([]byte, nameSize)
+	if _, err := io.ReadFull(cr.r, name); err != nil {
+		return nil, fmt.Errorf("truncated cpio entry name")
+	}
+	hdr.Name = strings.TrimRight(string(name), "\x00")
+	cr.remaining = hdr.Size
+	return hdr, nil
+}
+
+// Read reads from the current entry of the archive
+func (cr *cpioReader) Read(p []byte) (int, error) {
+	if cr.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > cr.remaining {
+		p = p[:cr.remaining]
+	}
+	n, err := cr.r.Read(p)
+	cr.remaining -= int64(n)
+	if err == io.EOF && cr.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// padding returns the number of bytes needed to pad n to a multiple of align
+func padding(n int64, align int64) int64 {
+	return (align - n%align) % align
+}
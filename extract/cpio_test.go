@@ -0,0 +1,143 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// walkedEntry is an entry reported to a walkFunc, with its contents
+type walkedEntry struct {
+	name     string
+	typ      entryType
+	linkname string
+	data     string
+}
+
+// walkAll returns the entries the given walker reports, reading the contents
+// of regular files and symlinks
+func walkAll(t *testing.T, walk func(walkFunc) error) []walkedEntry {
+	t.Helper()
+	walked := make([]walkedEntry, 0)
+	err := walk(func(e *entry) error {
+		w := walkedEntry{name: e.Name, typ: e.Type, linkname: e.Linkname}
+		if e.Type == typeRegular || e.Type == typeSymlink {
+			rc, err := e.Open()
+			if err != nil {
+				return err
+			}
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+			w.data = string(data)
+		}
+		walked = append(walked, w)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return walked
+}
+
+// cpioTestEntry is an entry of a cpio archive built by cpioBytes
+type cpioTestEntry struct {
+	name  string
+	ino   int64
+	mode  int64
+	nlink int64
+	data  string
+}
+
+// cpioBytes returns a cpio archive of the given entries in the newc format,
+// or the odc format if odc is set
+func cpioBytes(odc bool, entries []cpioTestEntry) []byte {
+	var buf bytes.Buffer
+	pad := func(n int64) {
+		if !odc {
+			buf.Write(make([]byte, padding(n, 4)))
+		}
+	}
+	entries = append(entries, cpioTestEntry{name: cpioTrailer, nlink: 1})
+	for _, e := range entries {
+		nameSize := int64(len(e.name) + 1)
+		size := int64(len(e.data))
+		if odc {
+			fmt.Fprintf(&buf, "070707%06o%06o%06o%06o%06o%06o%06o%011o%06o%011o", 0, e.ino, e.mode, 0, 0, e.nlink, 0, 1700000000, nameSize, size)
+		} else {
+			fmt.Fprintf(&buf, "070701%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x", e.ino, e.mode, 0, 0, e.nlink, 1700000000, size, 0, 0, 0, 0, nameSize, 0)
+		}
+		buf.WriteString(e.name + "\x00")
+		pad(110 + nameSize)
+		buf.WriteString(e.data)
+		pad(size)
+	}
+	return buf.Bytes()
+}
+
+func TestWalkCpio(t *testing.T) {
+	tests := []struct {
+		format  string
+		odc     bool
+		entries []cpioTestEntry
+		want    []walkedEntry
+	}{
+		{
+			// newc stores the data of hardlinked files with the last link
+			format: "newc",
+			entries: []cpioTestEntry{
+				{name: "bin", ino: 1, mode: 0040755, nlink: 2},
+				{name: "bin/a", ino: 2, mode: 0100755, nlink: 2},
+				{name: "bin/b", ino: 2, mode: 0100755, nlink: 2, data: "tool\n"},
+				{name: "bin/c", ino: 3, mode: 0120777, nlink: 1, data: "b"},
+				{name: "odd", ino: 4, mode: 0100644, nlink: 1, data: "x"},
+				{name: "lost", ino: 5, mode: 0100644, nlink: 2},
+			},
+			want: []walkedEntry{
+				{name: "bin", typ: typeDir},
+				{name: "bin/b", typ: typeRegular, data: "tool\n"},
+				{name: "bin/a", typ: typeHardlink, linkname: "bin/b"},
+				{name: "bin/c", typ: typeSymlink, data: "b"},
+				{name: "odd", typ: typeRegular, data: "x"},
+				{name: "lost", typ: typeRegular},
+			},
+		},
+		{
+			// odc stores the data of hardlinked files with the first link
+			format: "odc",
+			odc:    true,
+			entries: []cpioTestEntry{
+				{name: "bin/a", ino: 2, mode: 0100755, nlink: 2, data: "tool\n"},
+				{name: "bin/b", ino: 2, mode: 0100755, nlink: 2},
+				{name: "bin/c", ino: 3, mode: 0120777, nlink: 1, data: "a"},
+				{name: "pipe", ino: 4, mode: 0010644, nlink: 1},
+			},
+			want: []walkedEntry{
+				{name: "bin/a", typ: typeRegular, data: "tool\n"},
+				{name: "bin/b", typ: typeHardlink, linkname: "bin/a"},
+				{name: "bin/c", typ: typeSymlink, data: "a"},
+				{name: "pipe", typ: typeFifo},
+			},
+		},
+	}
+	for _, tc := range tests {
+		archive := cpioBytes(tc.odc, tc.entries)
+		got := walkAll(t, func(fn walkFunc) error { return walkCpio(bytes.NewReader(archive), fn) })
+		if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("%s:\n got %v\nwant %v", tc.format, got, tc.want)
+		}
+	}
+}
+
+func TestWalkCpioTruncated(t *testing.T) {
+	archive := cpioBytes(false, []cpioTestEntry{{name: "tool", ino: 1, mode: 0100755, nlink: 1, data: "tool\n"}})
+	for _, size := range []int{3, 60, 115} {
+		err := walkCpio(bytes.NewReader(archive[:size]), func(e *entry) error { return nil })
+		if err == nil {
+			t.Errorf("expected an error for an archive truncated to %d bytes", size)
+		}
+	}
+}
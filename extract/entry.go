@@ -0,0 +1,293 @@
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/backplane/ghlatest/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// entryType identifies the kind of file an archive entry describes
+type entryType int
+
+const (
+	typeRegular entryType = iota
+	typeDir
+	typeSymlink
+	typeHardlink
+	typeFifo
+	typeChar
+	typeBlock
+	typeUnsupported
+)
+
+var entryTypeNames = map[entryType]string{
+	typeRegular:     "file",
+	typeDir:         "dir",
+	typeSymlink:     "symlink",
+	typeHardlink:    "hardlink",
+	typeFifo:        "fifo",
+	typeChar:        "char",
+	typeBlock:       "block",
+	typeUnsupported: "unsupported",
+}
+
+// entry is a file, directory, link or device node read from an archive
+type entry struct {
+	Name     string      // path of the entry within the archive
+	Type     entryType   // kind of file
+	Mode     fs.FileMode // permission bits
+	Size     int64       // size of the contents
+	Linkname string      // link target, for symlinks it may instead be stored in the contents
+	Sparse   bool        // the contents contain holes which needn't be written
+	DevMajor int64       // device numbers for char and block devices
+	DevMinor int64
+	Reason   string // explanation for unsupported entries
+	Meta     entryMetadata
+
+	// Open returns a reader for the entry's contents. For sequential formats
+	// the reader is only valid until the walkFunc returns.
+	Open func() (io.ReadCloser, error)
+}
+
+// walkFunc is called for each entry of an archive, returning an error stops
+// the walk and the error is returned by the walker
+type walkFunc func(e *entry) error
+
+// streamOpener returns an entry Open function for sequential formats in which
+// the entry's contents are read from the archive stream itself
+func streamOpener(r io.Reader) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(r), nil
+	}
+}
+
+// extractor writes the archive entries it's given to the filesystem, keeping
+// a Result that describes what happened to each one
+type extractor struct {
+	outputDir string
+	keep      util.KeepSet
+	opts      Options
+	md        *metadataWriter
+	written   map[string]string // archive path -> output path
+	res       *Result
+	depth     int             // nesting level of the archive currently being walked
+	listing   []ListEntry     // when non-nil, entries are listed instead of written
+	planned   map[string]bool // output paths a dry run would have written
+}
+
+// newExtractor returns an extractor which writes the entries selected by the
+// given KeepSet
+func newExtractor(outputDir string, keep util.KeepSet, opts Options) *extractor {
+	return &extractor{
+		outputDir: outputDir,
+		keep:      keep,
+		opts:      opts,
+		md:        newMetadataWriter(opts),
+		written:   make(map[string]string),
+		res:       newResult(),
+		planned:   make(map[string]bool),
+	}
+}
+
+// keepAll makes the extractor select every entry unchanged, rather than
+// applying the keep rules, until the returned function is called. It's used
+// for the contents of nested archives which the keep rules selected.
+func (x *extractor) keepAll() func() {
+	keep := x.keep
+	x.keep = nil
+	return func() { x.keep = keep }
+}
+
+// fail records an entry which couldn't be extracted. It returns
+// errStopExtraction if the rest of the archive should be abandoned.
+func (x *extractor) fail(e *entry, path string, err error) error {
+	x.res.failed(e.Name, path, err.Error())
+	if x.opts.OnError == StopOnError {
+		log.Errorf("%s: %s; skipping any remaining files in archive", e.Name, err)
+		return errStopExtraction
+	}
+	log.Errorf("%s: %s; continuing with the next file", e.Name, err)
+	return nil
+}
+
+// skip records an entry which is deliberately not being extracted
+func (x *extractor) skip(e *entry, path string, reason string) {
+	log.Warnf("%s: skipped; %s", e.Name, reason)
+	x.res.skipped(e.Name, path, reason)
+}
+
+// extract is a walkFunc which writes the given entry to the filesystem
+func (x *extractor) extract(e *entry) error {
+	if e.Type == typeRegular && x.depth < x.opts.Nested {
+		// only the entries which the keep rules select are examined
+		if outPath, selected := x.keep.Select(util.NormalizeFilePath(e.Name)); selected {
+			handled, stream, err := x.nested(e, outPath)
+			if handled {
+				return err
+			}
+			defer stream.Close()
+			if stream.Op == opWriteSingleton {
+				// the decompressed file is named for the selected one
+				defer x.keepAll()()
+			}
+		}
+	}
+	if x.listing != nil {
+		x.list(e)
+		return nil
+	}
+	if x.opts.DryRun {
+		return x.plan(e)
+	}
+
+	filePath, selected, err := selectOutputPath(x.outputDir, x.keep, e.Name)
+	if !selected {
+		x.res.skipped(e.Name, "", "not selected by the keep rules")
+		return nil
+	}
+	if err != nil {
+		return x.fail(e, filePath, fmt.Errorf("creating parent directories failed; error: %s", err))
+	}
+
+	executable := false
+	switch e.Type {
+	case typeRegular:
+		if err := removeSymlink(filePath, x.opts.Overwrite); err != nil {
+			return x.fail(e, filePath, fmt.Errorf("removing existing symlink failed; error: %s", err))
+		}
+		contents, err := e.Open()
+		if err != nil {
+			return x.fail(e, filePath, fmt.Errorf("opening source contents failed; error: %s", err))
+		}
+		var src io.Reader = contents
+		mode := e.Mode
+		if x.opts.Bin {
+			src, mode, executable = detectExecutable(contents, mode)
+		}
+		if e.Sparse {
+			// the reader fills the holes with zeros, seek over them instead
+			log.Debugf("%s: writing sparse file", filePath)
+			_, err = util.NewSparseFileFromSource(filePath, mode, x.opts.Overwrite, src)
+		} else {
+			_, err = util.NewFileFromSource(filePath, mode, x.opts.Overwrite, src)
+		}
+		contents.Close()
+		if err != nil {
+			return x.fail(e, filePath, fmt.Errorf("extracting file failed; error: %s", err))
+		}
+		x.md.apply(filePath, e.Meta, false)
+	case typeDir:
+		if err := util.NewDirectory(filePath, e.Mode); err != nil {
+			return x.fail(e, filePath, fmt.Errorf("mkdir failed; error: %s", err))
+		}
+		x.md.deferDir(filePath, e.Meta)
+	case typeSymlink:
+		target := e.Linkname
+		if target == "" && e.Open != nil {
+			// the link target is stored as the entry's contents
+			if target, err = readLinkTarget(e.Open); err != nil {
+				return x.fail(e, filePath, fmt.Errorf("reading symlink target failed; error: %s", err))
+			}
+		}
+		if err := newSymlink(x.outputDir, target, filePath, x.opts.Overwrite); err != nil {
+			return x.fail(e, filePath, fmt.Errorf("creating symlink failed; error: %s", err))
+		}
+		x.md.apply(filePath, e.Meta, true)
+	case typeHardlink:
+		// hardlink targets are archive paths, resolve them to the path the
+		// target was extracted to (which may have been renamed)
+		linkTarget, extracted := x.written[util.NormalizeFilePath(e.Linkname)]
+		if !extracted {
+			x.skip(e, filePath, fmt.Sprintf("its hardlink target \"%s\" was not extracted", e.Linkname))
+			return nil
+		}
+		if err := newHardlink(x.outputDir, linkTarget, filePath, x.opts.Overwrite); err != nil {
+			return x.fail(e, filePath, fmt.Errorf("creating hardlink failed; error: %s", err))
+		}
+	case typeFifo:
+		err := newFifo(filePath, e.Mode, x.opts.Overwrite)
+		if errors.Is(err, errNodesUnsupported) {
+			x.skip(e, filePath, fmt.Sprintf("creating fifo failed; error: %s", err))
+			return nil
+		}
+		if err != nil {
+			return x.fail(e, filePath, fmt.Errorf("mkfifo failed; error: %s", err))
+		}
+		x.md.apply(filePath, e.Meta, false)
+	case typeChar, typeBlock:
+		err := newDevice(filePath, e.Mode, e.Type == typeChar, e.DevMajor, e.DevMinor, x.opts.Overwrite)
+		if errors.Is(err, fs.ErrPermission) || errors.Is(err, errNodesUnsupported) {
+			x.skip(e, filePath, fmt.Sprintf("creating device node failed; error: %s", err))
+			return nil
+		}
+		if err != nil {
+			return x.fail(e, filePath, fmt.Errorf("mknod failed; error: %s", err))
+		}
+		x.md.apply(filePath, e.Meta, false)
+	default:
+		x.skip(e, filePath, e.Reason)
+		return nil
+	}
+
+	x.written[util.NormalizeFilePath(e.Name)] = filePath
+	if executable {
+		log.Infof("%s: found executable", filePath)
+		x.res.executable(e.Name, filePath)
+	} else {
+		x.res.extracted(e.Name, filePath)
+	}
+	return nil
+}
+
+// plan records what extracting the given entry would do, without changing the
+// filesystem
+func (x *extractor) plan(e *entry) error {
+	filePath, selected := x.keep.Select(util.NormalizeFilePath(e.Name))
+	if !selected {
+		x.res.skipped(e.Name, "", "not selected by the keep rules")
+		return nil
+	}
+	filePath = inOutputDir(x.outputDir, filePath)
+
+	switch e.Type {
+	case typeHardlink:
+		if _, extracted := x.written[util.NormalizeFilePath(e.Linkname)]; !extracted {
+			x.skip(e, filePath, fmt.Sprintf("its hardlink target \"%s\" was not extracted", e.Linkname))
+			return nil
+		}
+	case typeUnsupported:
+		x.skip(e, filePath, e.Reason)
+		return nil
+	}
+
+	action, err := util.PlanFile(filePath, e.Type == typeDir, x.opts.Overwrite)
+	if x.planned[filePath] && e.Type != typeDir {
+		// an earlier entry would already have created this path
+		action, err = "overwrite", nil
+		if !x.opts.Overwrite {
+			err = fmt.Errorf("\"%s\" would already exist; use --overwrite to replace it", filePath)
+		}
+	}
+	if err != nil {
+		return x.fail(e, filePath, err)
+	}
+	x.planned[filePath] = true
+	x.written[util.NormalizeFilePath(e.Name)] = filePath
+	x.res.planned(e.Name, filePath, action)
+	return nil
+}
+
+// finish applies any deferred metadata and returns the Result of the
+// extraction. The given error is the one returned by the archive walker,
+// stops requested by the extractor itself aren't treated as errors.
+func (x *extractor) finish(walkErr error) (*Result, error) {
+	x.md.finish()
+	if walkErr != nil && !errors.Is(walkErr, errStopExtraction) {
+		return x.res, walkErr
+	}
+	return x.res, nil
+}
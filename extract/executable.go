@@ -0,0 +1,33 @@
+package extract
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/fs"
+)
+
+// isExecutable reports whether the given header is the start of an ELF,
+// Mach-O or PE executable or of a script with a shebang line
+func isExecutable(header []byte) bool {
+	if bytes.HasPrefix(header, []byte("#!")) {
+		return true
+	}
+	m := matchMagic(header, archiveMagics)
+	return m != nil && m.Operations[0] == opWriteRaw
+}
+
+// detectExecutable peeks at the start of r to determine whether its contents
+// are an executable. It returns a reader for the full contents, along with
+// the given mode with the executable bits set (for everyone who may read the
+// file) if they are, or cleared if they aren't.
+func detectExecutable(r io.Reader, mode fs.FileMode) (io.Reader, fs.FileMode, bool) {
+	br := bufio.NewReader(r)
+	// short or failed reads are inconclusive, any error resurfaces when the
+	// contents are read
+	header, _ := br.Peek(4)
+	if isExecutable(header) {
+		return br, mode | (mode&0444)>>2, true
+	}
+	return br, mode &^ 0111, false
+}
@@ -1,8 +1,9 @@
 // Extract implements the extraction of files from various file archive or compression formats.
 //
 // The entrypoint for the package is ExtractFile which is meant to call the
-// appropriate handlers for the file at the given path based on the filename
-// extensions. OpenArchive may also be used. The resulting Archive struct has
+// appropriate handlers for the file at the given path based on the magic
+// bytes at the start of the file, with the filename extensions used as a
+// fallback. OpenArchive may also be used. The resulting Archive struct has
 // methods which handle common archive types.
 package extract
 
@@ -10,7 +11,10 @@ package extract
 
 import (
 	"fmt"
+	"io"
+	"io/fs"
 	"regexp"
+	"strings"
 
 	"github.com/backplane/ghlatest/util"
 	log "github.com/sirupsen/logrus"
@@ -23,31 +27,59 @@ type aop int
 const (
 	opUnbzip2 aop = iota
 	opUngzip
+	opUnlz4
 	opUnxz
+	opUnzstd
 )
 
 // >= 100 = single-file writers
 const (
 	opWriteSingleton aop = iota + 100
+	opWriteRaw
 )
 
 // >= 200 = multi-file writers
 const (
 	opUn7z aop = iota + 200
+	opUnar
+	opUncpio
+	opUndeb
+	opUnrpm
 	opUntar
 	opUnzip
 )
 
 var archiveOpNames = map[aop]string{
 	opUn7z:           "7z",
+	opUnar:           "ar",
 	opUnbzip2:        "bzip2",
+	opUncpio:         "cpio",
+	opUndeb:          "deb",
 	opUngzip:         "gzip",
+	opUnlz4:          "lz4",
+	opUnrpm:          "rpm",
 	opUntar:          "tar",
 	opUnxz:           "xz",
 	opUnzip:          "zip",
+	opUnzstd:         "zstd",
+	opWriteRaw:       "raw",
 	opWriteSingleton: "file",
 }
 
+// Options controls how the files in an archive are written
+type Options struct {
+	Overwrite bool      // replace existing files which conflict with extracted files
+	SameOwner bool      // restore file ownership from the archive (requires root)
+	Xattrs    bool      // restore extended attributes (incl. capabilities) from tar archives
+	OnError   ErrorMode // stop or continue after an entry fails to extract
+	Nested    int       // extract archives found within the archive, up to this many levels deep
+	DryRun    bool      // report what would be written without changing the filesystem
+	Name      string    // the archive's name if it differs from its path, e.g. for temporary downloads
+	Format    string    // the archive's format as a filename extension (e.g. "tar.gz"), overriding detection
+	Bin       bool      // set the executable bits only on the files which are executables
+	OutputDir string    // directory the files are extracted to, the current directory if empty
+}
+
 type filenameStrategy struct {
 	FilenameRegexp *regexp.Regexp
 	Operations     []aop
@@ -57,96 +89,286 @@ var strategies = []filenameStrategy{
 	{regexp.MustCompile(`(?i)\.7z$`), []aop{opUn7z}},
 	{regexp.MustCompile(`(?i)\.tar$`), []aop{opUntar}},
 	{regexp.MustCompile(`(?i)\.zip$`), []aop{opUnzip}},
+	{regexp.MustCompile(`(?i)\.u?deb$`), []aop{opUndeb}},
+	{regexp.MustCompile(`(?i)\.rpm$`), []aop{opUnrpm}},
+	{regexp.MustCompile(`(?i)\.cpio$`), []aop{opUncpio}},
+	{regexp.MustCompile(`(?i)\.(a|ar)$`), []aop{opUnar}},
+	{regexp.MustCompile(`(?i)\.cpio\.gz$`), []aop{opUngzip, opUncpio}},
 	{regexp.MustCompile(`(?i)\.(tbz2|tar\.bz2)$`), []aop{opUnbzip2, opUntar}},
 	{regexp.MustCompile(`(?i)\.(tgz|tar\.gz)$`), []aop{opUngzip, opUntar}},
 	{regexp.MustCompile(`(?i)\.(txz|tar\.xz)$`), []aop{opUnxz, opUntar}},
+	{regexp.MustCompile(`(?i)\.(tzst|tar\.zst)$`), []aop{opUnzstd, opUntar}},
+	{regexp.MustCompile(`(?i)\.tar\.lz4$`), []aop{opUnlz4, opUntar}},
 	{regexp.MustCompile(`(?i)\.bz2$`), []aop{opUnbzip2, opWriteSingleton}},
 	{regexp.MustCompile(`(?i)\.gz$`), []aop{opUngzip, opWriteSingleton}},
 	{regexp.MustCompile(`(?i)\.xz$`), []aop{opUnxz, opWriteSingleton}},
+	{regexp.MustCompile(`(?i)\.zst$`), []aop{opUnzstd, opWriteSingleton}},
+	{regexp.MustCompile(`(?i)\.lz4$`), []aop{opUnlz4, opWriteSingleton}},
 }
 
-// ExtractFile extracts the contents of the file archive at the given filePath.
-// The filterStrings argument accepts a slice of strings (which will be compiled
-// into [regexp.Regexp] objects) to filter what will be extracted from the file
-// archive.
-func ExtractFile(filePath string, filterStrings []string, overwrite bool) error {
-	filters, err := util.CompileFilters(filterStrings)
-	if err != nil {
-		log.Fatalf("failed to compile --keep filters, error: %s", err)
+// prepareArchive opens the archive at the given filePath, identifies its
+// format and applies any decompression operations it needs. If opts.Name is
+// set it replaces the filePath when matching filename extensions, naming
+// single-file output and logging; if opts.Format is set it's used instead of
+// detecting the format. It returns the
+// Archive along with the name of the detected format (if any) and the final
+// operation, which writes the files. The caller must close the Archive.
+func prepareArchive(filePath string, opts Options) (*Archive, string, aop, error) {
+	var forced []aop
+	if opts.Format != "" {
+		var err error
+		if forced, err = formatOperations(opts.Format); err != nil {
+			return nil, "", 0, err
+		}
 	}
 
 	a, err := OpenArchive(filePath)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, "", 0, err
+	}
+	if opts.Name != "" {
+		a.Path = opts.Name
+		filePath = opts.Name
 	}
-	defer a.Close()
-
-	var outputDir string = "."
 
+	// the filename extension selects the output name and the fallback operations
+	var byName []aop
+	a.PathNoExt = filePath
 	for _, strategy := range strategies {
 		if !strategy.FilenameRegexp.MatchString(filePath) {
 			continue
 		}
-		// it's important to populate this with the first matcher found because we
-		// need to support different handlers for example.tar.gz and example.gz
+		// it's important to use the first matcher found because we need to
+		// support different handlers for example.tar.gz and example.gz
 		a.PathNoExt = strategy.FilenameRegexp.ReplaceAllString(filePath, "")
-		for _, op := range strategy.Operations {
-			var err error
-			var extractedFiles []string
-
-			// op >= 200: multi-file writers, which always terminate the operation list
-			if op >= 200 {
-				log.Infof("extracting (%s) %s", archiveOpNames[op], a.Path)
-				switch op {
-				case opUn7z:
-					extractedFiles = a.Un7z(outputDir, filters, overwrite)
-				case opUntar:
-					extractedFiles = a.Untar(outputDir, filters, overwrite)
-				case opUnzip:
-					extractedFiles = a.Unzip(outputDir, filters, overwrite)
-				default:
-					panic(fmt.Sprintf("Encountered unhandled archive operation %d (%s)", op, archiveOpNames[op]))
-				}
-				if len(extractedFiles) < 1 {
-					return fmt.Errorf("no files were extracted from archive; stopping extraction")
-				}
-				return nil
-			}
-
-			// op >= 100: single-file writers which always terminate the operation list
-			if op >= 100 {
-				log.Debugf("writing decompressed contents of %s", a.Path)
-				switch op {
-				case opWriteSingleton:
-					err = a.WriteSingleton(a.PathNoExt, a.FileStats.Mode().Perm(), overwrite)
-				default:
-					panic(fmt.Sprintf("Encountered unhandled archive operation %d (%s)", op, archiveOpNames[op]))
-				}
-				return err
-			}
-
-			// op < 100: decompressors that don't write files and never terminate the operation list
-			log.Infof("decompressing (%s) %s", archiveOpNames[op], a.Path)
-			switch op {
-			case opUnbzip2:
-				err = a.Unbzip2()
-			case opUngzip:
-				err = a.Ungzip()
-			case opUnxz:
-				err = a.Unxz()
-			default:
-				panic(fmt.Sprintf("Encountered unhandled archive operation %d (%s)", op, archiveOpNames[op]))
-			}
-			if err != nil {
-				return fmt.Errorf(`decompressing (%s) "%s" failed; error:%s`, archiveOpNames[op], a.Path, err)
-			}
-			continue
+		byName = strategy.Operations
+		break
+	}
+
+	// the contents of the file take precedence over the filename extension,
+	// unless the format was given
+	format, operations := opts.Format, forced
+	if forced == nil {
+		if format, operations, err = a.sniffOperations(byName); err != nil {
+			a.Close()
+			return nil, "", 0, fmt.Errorf(`reading "%s" failed; error: %s`, filePath, err)
+		}
+	}
+	if operations == nil {
+		a.Close()
+		if format != "" {
+			return nil, "", 0, fmt.Errorf(`file:"%s" is in %s format, which is not supported`, filePath, format)
+		}
+		return nil, "", 0, fmt.Errorf(`don't know how to extract file:"%s"`, filePath)
+	}
+	if format != "" {
+		log.Debugf("detected %s format for %s", format, filePath)
+	}
+
+	for _, op := range operations {
+		// op >= 100: file writers, which always terminate the operation list
+		if op >= 100 {
+			return a, format, op, nil
 		}
 
-		// none of the operations returned from this function
-		panic("reached code that should be unreachable oplists should terminate with ops >=100 but were're still here")
+		// op < 100: decompressors that don't write files and never terminate the operation list
+		log.Infof("decompressing (%s) %s", archiveOpNames[op], a.Path)
+		switch op {
+		case opUnbzip2:
+			err = a.Unbzip2()
+		case opUngzip:
+			err = a.Ungzip()
+		case opUnlz4:
+			err = a.Unlz4()
+		case opUnxz:
+			err = a.Unxz()
+		case opUnzstd:
+			err = a.Unzstd()
+		default:
+			panic(fmt.Sprintf("Encountered unhandled archive operation %d (%s)", op, archiveOpNames[op]))
+		}
+		if err != nil {
+			a.Close()
+			return nil, "", 0, fmt.Errorf(`decompressing (%s) "%s" failed; error:%s`, archiveOpNames[op], a.Path, err)
+		}
+	}
+
+	// none of the operations returned from this function
+	panic("reached code that should be unreachable oplists should terminate with ops >=100 but were're still here")
+}
+
+// walk calls fn for each entry of the Archive using the walker for the given
+// multi-file operation
+func (a *Archive) walk(op aop, fn walkFunc) error {
+	if walk, ok := streamWalkers[op]; ok {
+		return walk(a.stream(), fn)
+	}
+	if walk, ok := fileWalkers[op]; ok {
+		return walk(a.FileHandle, a.FileStats.Size(), fn)
+	}
+	panic(fmt.Sprintf("Encountered unhandled archive operation %d (%s)", op, archiveOpNames[op]))
+}
+
+// formatOperations returns the operations for the given format, which is
+// named by its filename extension (e.g. "tar.gz", "tgz" or "zip")
+func formatOperations(format string) ([]aop, error) {
+	name := "archive." + strings.TrimPrefix(format, ".")
+	for _, strategy := range strategies {
+		if strategy.FilenameRegexp.MatchString(name) {
+			return strategy.Operations, nil
+		}
 	}
+	return nil, fmt.Errorf("unknown archive format \"%s\", it should be a filename extension like \"tar.gz\" or \"zip\"", format)
+}
 
-	// none of the matchers matched
-	return fmt.Errorf(`don't know how to extract file:"%s"`, filePath)
+// ExtractFile extracts the contents of the file archive at the given filePath.
+// The keepStrings argument accepts a slice of strings (which will be compiled
+// into a [util.KeepSet]) to filter what will be extracted from the file
+// archive. Keep strings of the form "regex=>replacement" also rename the
+// matching files. The opts argument controls how files are written. The
+// returned Result lists the entries which were extracted, skipped or which
+// failed; if any failed an error is returned along with the Result. When
+// opts.Bin is set the executables are marked in the Result, and if keep rules
+// were given but none of the selected files is an executable an error is
+// returned.
+func ExtractFile(filePath string, keepStrings []string, opts Options) (*Result, error) {
+	res, err := extractFile(filePath, keepStrings, opts)
+	return checkExecutables(res, err, keepStrings, opts)
+}
+
+// extractFile implements ExtractFile
+func extractFile(filePath string, keepStrings []string, opts Options) (*Result, error) {
+	keep, err := util.CompileKeepRules(keepStrings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile --keep filters, error: %s", err)
+	}
+
+	a, format, op, err := prepareArchive(filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+
+	var outputDir string = "."
+	if opts.OutputDir != "" {
+		outputDir = opts.OutputDir
+	}
+	var res *Result
+
+	// op >= 200: multi-file writers
+	if op >= 200 {
+		log.Infof("extracting (%s) %s", archiveOpNames[op], a.Path)
+		switch op {
+		case opUn7z:
+			res, err = a.Un7z(outputDir, keep, opts)
+		case opUnar:
+			res, err = a.Unar(outputDir, keep, opts)
+		case opUncpio:
+			res, err = a.Uncpio(outputDir, keep, opts)
+		case opUndeb:
+			res, err = a.Undeb(outputDir, keep, opts)
+		case opUnrpm:
+			res, err = a.Unrpm(outputDir, keep, opts)
+		case opUntar:
+			res, err = a.Untar(outputDir, keep, opts)
+		case opUnzip:
+			res, err = a.Unzip(outputDir, keep, opts)
+		default:
+			panic(fmt.Sprintf("Encountered unhandled archive operation %d (%s)", op, archiveOpNames[op]))
+		}
+		return archiveResult(res, err, archiveOpNames[op], a.Path)
+	}
+
+	// op >= 100: single-file writers
+	var outputPath string
+	switch op {
+	case opWriteSingleton:
+		outputPath = keep.Rename(a.PathNoExt)
+		if outputPath == a.Path {
+			return nil, fmt.Errorf(`can't choose an output name for the decompressed contents of "%s"; use a --keep rename rule`, a.Path)
+		}
+		outputPath = inOutputDir(outputDir, outputPath)
+	case opWriteRaw:
+		// a file which isn't an archive is copied if it's renamed or if
+		// it's being extracted to another directory
+		outputPath = inOutputDir(outputDir, keep.Rename(a.Path))
+		if outputPath == a.Path {
+			log.Infof("%s is not an archive (%s); leaving it in place", a.Path, format)
+			res = newResult()
+			res.skipped(a.Path, a.Path, "not an archive")
+			return res, nil
+		}
+		a.StreamHandle = io.NopCloser(a.FileHandle)
+	default:
+		panic(fmt.Sprintf("Encountered unhandled archive operation %d (%s)", op, archiveOpNames[op]))
+	}
+	return writeSingleFile(a.StreamHandle, a.PathNoExt, outputPath, a.FileStats.Mode().Perm(), opts)
+}
+
+// archiveResult checks the Result of walking a multi-file archive, returning
+// an error if the walk failed, if any entries failed or if nothing was
+// extracted
+func archiveResult(res *Result, err error, format string, path string) (*Result, error) {
+	if err != nil {
+		return res, fmt.Errorf(`reading (%s) "%s" failed; error: %s`, format, path, err)
+	}
+	if err := res.Err(); err != nil {
+		return res, err
+	}
+	if len(res.Extracted) < 1 {
+		return res, fmt.Errorf("no files were extracted from archive; stopping extraction")
+	}
+	return res, nil
+}
+
+// checkExecutables checks that an extraction with opts.Bin and keep rules
+// found at least one executable
+func checkExecutables(res *Result, err error, keepStrings []string, opts Options) (*Result, error) {
+	if err != nil || !opts.Bin || opts.DryRun || len(keepStrings) == 0 {
+		return res, err
+	}
+	if len(res.Executables()) == 0 {
+		return res, fmt.Errorf("none of the files selected by the keep rules is an executable")
+	}
+	return res, nil
+}
+
+// writeSingleFile writes the contents of a single-file archive (e.g. a gzip
+// compressed file) with the given name to the given output path, creating
+// any missing parent directories. Dry runs only report what would happen.
+// With opts.Bin the given mode's executable bits are set or cleared depending
+// on the contents.
+func writeSingleFile(r io.Reader, name string, outputPath string, mode fs.FileMode, opts Options) (*Result, error) {
+	res := newResult()
+	if opts.DryRun {
+		action, err := util.PlanFile(outputPath, false, opts.Overwrite)
+		if err != nil {
+			res.failed(name, outputPath, err.Error())
+			return res, err
+		}
+		res.planned(name, outputPath, action)
+		return res, nil
+	}
+
+	executable := false
+	if opts.Bin {
+		r, mode, executable = detectExecutable(r, mode)
+	}
+
+	log.Debugf("writing the contents of %s to %s", name, outputPath)
+	err := util.NewParentDirectories(outputPath, 0755)
+	if err == nil {
+		_, err = util.NewFileFromSource(outputPath, mode, opts.Overwrite, r)
+	}
+	if err != nil {
+		res.failed(name, outputPath, err.Error())
+		return res, err
+	}
+	if executable {
+		log.Infof("%s: found executable", outputPath)
+		res.executable(name, outputPath)
+	} else {
+		res.extracted(name, outputPath)
+	}
+	return res, nil
 }
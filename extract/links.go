@@ -0,0 +1,222 @@
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// withinRoot reports whether the given path is the extraction root or is
+// located inside it. Both paths must be absolute and free of symlinks.
+func withinRoot(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// physicalRoot returns the absolute, symlink-free form of the extraction
+// root, which is created along with the first entry if it doesn't exist yet
+func physicalRoot(root string) (string, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	return resolveExisting(abs)
+}
+
+// physicalParent returns the absolute, symlink-free form of the directory
+// which will contain the given path, it must already exist
+func physicalParent(path string) (string, error) {
+	abs, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// resolveExisting returns the given absolute path with the symlinks in its
+// longest existing prefix resolved. Dangling symlinks are followed to their
+// targets, which may only lead outside of the root once other entries exist.
+// The components which don't exist yet are appended as they are, they will be
+// created as real directories or, for a symlink target, fail to resolve. The
+// path must not have been cleaned, so that ".." components following a
+// symlink are resolved physically.
+func resolveExisting(path string) (string, error) {
+	rest := ""
+	links := 0
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, syscall.ENOTDIR) {
+			return "", err
+		}
+		i := strings.LastIndexByte(path, filepath.Separator)
+		if i <= 0 {
+			return "", err
+		}
+		if target, linkErr := os.Readlink(path); linkErr == nil {
+			// limit the links followed, as the kernel does
+			if links++; links > 40 {
+				return "", fmt.Errorf("too many levels of symbolic links in \"%s\"", path)
+			}
+			if filepath.IsAbs(target) {
+				path = target
+			} else {
+				path = path[:i+1] + target
+			}
+			continue
+		}
+		rest = filepath.Join(path[i+1:], rest)
+		path = path[:i]
+	}
+}
+
+// checkWithinRoot returns an error if the given path, once the symlinks in
+// its existing part are resolved, is outside of the extraction root. It's
+// called before anything is created at the path or in its parents.
+func checkWithinRoot(root string, path string) error {
+	rootPath, err := physicalRoot(root)
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	resolved, err := resolveExisting(abs)
+	if err != nil {
+		return err
+	}
+	if !withinRoot(rootPath, resolved) {
+		return fmt.Errorf("\"%s\" resolves to \"%s\" which is outside of the extraction root", path, resolved)
+	}
+	return nil
+}
+
+// removeForOverwrite removes an existing file at the given path if overwrite
+// is set, so that a link may be created in its place
+func removeForOverwrite(path string, overwrite bool) error {
+	if !overwrite {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// removeSymlink removes an existing symlink at the given path if overwrite is
+// set, so that a file written in its place doesn't go through the link
+func removeSymlink(path string, overwrite bool) error {
+	if !overwrite {
+		return nil
+	}
+	stats, err := os.Lstat(path)
+	if err != nil || stats.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+	return os.Remove(path)
+}
+
+// newSymlink creates a symlink at linkPath pointing at target. The link is
+// refused if the target is absolute or would resolve to a location outside
+// of the extraction root, which guards against archives that write through
+// symlinks to arbitrary places in the filesystem.
+func newSymlink(root string, target string, linkPath string, overwrite bool) error {
+	if target == "" {
+		return fmt.Errorf("empty symlink target")
+	}
+	if filepath.IsAbs(target) || strings.HasPrefix(target, `\`) {
+		return fmt.Errorf("symlink target \"%s\" is an absolute path", target)
+	}
+	rootPath, err := physicalRoot(root)
+	if err != nil {
+		return err
+	}
+	parent, err := physicalParent(linkPath)
+	if err != nil {
+		return err
+	}
+	if !withinRoot(rootPath, parent) {
+		return fmt.Errorf("symlink location \"%s\" is outside of the extraction root", linkPath)
+	}
+	// the target is resolved through any symlinks it passes, a textual check
+	// would miss a ".." following a link to a parent directory
+	resolved, err := resolveExisting(parent + string(filepath.Separator) + filepath.FromSlash(target))
+	if err != nil {
+		return fmt.Errorf("resolving symlink target \"%s\" failed; error: %s", target, err)
+	}
+	if !withinRoot(rootPath, resolved) {
+		return fmt.Errorf("symlink target \"%s\" is outside of the extraction root", target)
+	}
+
+	if err := removeForOverwrite(linkPath, overwrite); err != nil {
+		return err
+	}
+	if err := os.Symlink(target, linkPath); err != nil {
+		return err
+	}
+	log.Infof("created symlink:\"%s\" -> \"%s\"", linkPath, target)
+	return nil
+}
+
+// newHardlink creates a hardlink at linkPath to the existing file at target.
+// Both paths are relative to the current working directory and must resolve
+// to locations within the extraction root.
+func newHardlink(root string, target string, linkPath string, overwrite bool) error {
+	rootPath, err := physicalRoot(root)
+	if err != nil {
+		return err
+	}
+	for _, path := range []string{target, linkPath} {
+		parent, err := physicalParent(path)
+		if err != nil {
+			return err
+		}
+		if !withinRoot(rootPath, filepath.Join(parent, filepath.Base(path))) {
+			return fmt.Errorf("hardlink path \"%s\" is outside of the extraction root", path)
+		}
+	}
+	if stats, err := os.Lstat(target); err != nil {
+		return err
+	} else if stats.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("hardlink target \"%s\" is a symlink", target)
+	}
+
+	if err := removeForOverwrite(linkPath, overwrite); err != nil {
+		return err
+	}
+	if err := os.Link(target, linkPath); err != nil {
+		return err
+	}
+	log.Infof("created hardlink:\"%s\" => \"%s\"", linkPath, target)
+	return nil
+}
+
+// readLinkTarget reads a symlink target which is stored as the contents of an
+// archive entry, as is done in the zip and 7z formats
+func readLinkTarget(open func() (io.ReadCloser, error)) (string, error) {
+	rc, err := open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	// targets are limited to PATH_MAX
+	target, err := io.ReadAll(io.LimitReader(rc, 4096))
+	if err != nil {
+		return "", err
+	}
+	return string(target), nil
+}
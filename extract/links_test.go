@@ -0,0 +1,117 @@
+package extract
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// writeTestTar writes a tar archive of the given headers to the given path,
+// the regular files contain the text "escaped\n"
+func writeTestTar(t *testing.T, path string, headers []*tar.Header) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	for _, hdr := range headers {
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = 8
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			if _, err := tw.Write([]byte("escaped\n")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// extractTestTar writes a tar archive of the given headers into a temporary
+// directory and extracts it into the "out" directory within it, returning the
+// temporary directory and the result
+func extractTestTar(t *testing.T, headers []*tar.Header, opts Options) (string, *Result) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks may require privileges on windows")
+	}
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "escape.tar")
+	writeTestTar(t, archivePath, headers)
+
+	opts.OutputDir = filepath.Join(dir, "out")
+	opts.OnError = SkipOnError
+	if err := os.Mkdir(opts.OutputDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	res, _ := ExtractFile(archivePath, nil, opts)
+	return dir, res
+}
+
+// TestSymlinkEscape extracts an archive whose symlinks only leave the
+// extraction root once they're resolved: x -> a/b/.. is a/ textually, but
+// a/b -> .. makes it the parent of the root
+func TestSymlinkEscape(t *testing.T) {
+	dir, res := extractTestTar(t, []*tar.Header{
+		{Name: "a/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "a/b", Typeflag: tar.TypeSymlink, Linkname: ".."},
+		{Name: "x", Typeflag: tar.TypeSymlink, Linkname: "a/b/.."},
+		{Name: "x/escaped.txt", Typeflag: tar.TypeReg, Mode: 0644},
+	}, Options{})
+
+	if _, err := os.Lstat(filepath.Join(dir, "escaped.txt")); err == nil {
+		t.Errorf("escaped.txt was written outside of the extraction root")
+	}
+	if stats, err := os.Lstat(filepath.Join(dir, "out", "x")); err == nil && stats.Mode()&os.ModeSymlink != 0 {
+		t.Errorf("the escaping symlink x was created")
+	}
+	if res == nil || len(res.Failed) != 1 || res.Failed[0].Name != "x" {
+		t.Errorf("expected only the symlink x to fail, got %+v", res)
+	}
+}
+
+// TestOverwriteSymlinkEscape extracts an archive with --overwrite whose
+// symlink m -> a/b/../../pwned only leaves the extraction root once the later
+// a -> . exists, and which then writes a regular file over m
+func TestOverwriteSymlinkEscape(t *testing.T) {
+	dir, res := extractTestTar(t, []*tar.Header{
+		{Name: "m", Typeflag: tar.TypeSymlink, Linkname: "a/b/../../pwned"},
+		{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "."},
+		{Name: "b/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "m", Typeflag: tar.TypeReg, Mode: 0644},
+	}, Options{Overwrite: true})
+
+	if _, err := os.Lstat(filepath.Join(dir, "pwned")); err == nil {
+		t.Errorf("pwned was written outside of the extraction root")
+	}
+	if res == nil || len(res.Failed) != 1 || res.Failed[0].Name != "m" {
+		t.Errorf("expected only the regular file m to fail, got %+v", res)
+	}
+}
+
+// TestMissingOutputDir extracts into an output directory which doesn't exist
+// yet, it's created along with the first entry
+func TestMissingOutputDir(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "files.tar")
+	writeTestTar(t, archivePath, []*tar.Header{
+		{Name: "bin/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "bin/tool", Typeflag: tar.TypeReg, Mode: 0755},
+	})
+	res, err := ExtractFile(archivePath, nil, Options{OutputDir: filepath.Join(dir, "out", "files")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Extracted) != 2 {
+		t.Errorf("expected both entries to be extracted, got %+v", res)
+	}
+}
@@ -0,0 +1,148 @@
+package extract
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/backplane/ghlatest/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// ListEntry describes an entry of an archive and what extraction would do
+// with it
+type ListEntry struct {
+	Path     string    `json:"path"`               // normalized path of the entry within the archive
+	Type     string    `json:"type"`               // file, dir, symlink, hardlink, fifo, char, block or unsupported
+	Size     int64     `json:"size"`               // size of the contents, -1 if unknown
+	Mode     string    `json:"mode"`               // permission bits in octal, "-" if unknown
+	ModTime  time.Time `json:"mtime"`              // modification time, zero if not recorded
+	Linkname string    `json:"linkname,omitempty"` // target of symlinks and hardlinks
+	Selected bool      `json:"selected"`           // the keep rules select this entry
+	Output   string    `json:"output,omitempty"`   // path the entry would be extracted to
+}
+
+// list records the given entry in the listing instead of writing it to the
+// filesystem
+func (x *extractor) list(e *entry) {
+	name := util.NormalizeFilePath(e.Name)
+	outPath, selected := x.keep.Select(name)
+	le := ListEntry{
+		Path:     name,
+		Type:     entryTypeNames[e.Type],
+		Size:     e.Size,
+		Mode:     fmt.Sprintf("%04o", e.Mode.Perm()),
+		ModTime:  e.Meta.ModTime,
+		Linkname: e.Linkname,
+		Selected: selected,
+	}
+	if e.Type == typeSymlink && le.Linkname == "" && e.Open != nil {
+		// the link target is stored as the entry's contents
+		if target, err := readLinkTarget(e.Open); err == nil {
+			le.Linkname = target
+		} else {
+			log.Warnf("%s: reading symlink target failed; error: %s", e.Name, err)
+		}
+	}
+	if selected {
+		le.Output = outPath
+	}
+	x.listing = append(x.listing, le)
+}
+
+// ListFile lists the contents of the file archive at the given filePath
+// without writing anything. The keepStrings argument is compiled into a
+// [util.KeepSet] and each ListEntry records whether it selects the entry and
+// the path the entry would be extracted to. When opts.Nested is set, the
+// nested archives which the keep rules select are listed in place of
+// themselves, with all of their entries selected, as they would be extracted.
+func ListFile(filePath string, keepStrings []string, opts Options) ([]ListEntry, error) {
+	keep, err := util.CompileKeepRules(keepStrings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile --keep filters, error: %s", err)
+	}
+
+	a, format, op, err := prepareArchive(filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+
+	// op >= 200: multi-file archives
+	if op >= 200 {
+		log.Infof("listing (%s) %s", archiveOpNames[op], a.Path)
+		x := newExtractor(".", keep, opts)
+		x.listing = make([]ListEntry, 0)
+		if _, err := x.finish(a.walk(op, x.extract)); err != nil {
+			return x.listing, fmt.Errorf(`reading (%s) "%s" failed; error: %s`, archiveOpNames[op], a.Path, err)
+		}
+		return x.listing, x.res.Err()
+	}
+
+	// op >= 100: single files, either compressed or raw
+	le := ListEntry{
+		Path:    a.PathNoExt,
+		Type:    entryTypeNames[typeRegular],
+		Size:    -1,
+		Mode:    fmt.Sprintf("%04o", a.FileStats.Mode().Perm()),
+		ModTime: a.FileStats.ModTime(),
+	}
+	if op == opWriteRaw {
+		log.Infof("%s is not an archive (%s)", a.Path, format)
+		le.Path = a.Path
+		le.Size = a.FileStats.Size()
+	}
+	if outPath := keep.Rename(le.Path); outPath != a.Path {
+		le.Selected = true
+		le.Output = outPath
+	}
+	return []ListEntry{le}, nil
+}
+
+// ListReader lists the contents of the archive read from r, which has the
+// given name ("-" for stdin), in the same way as ListFile. Nothing is written
+// except for zip and 7z archives, which are spooled to a temporary file.
+func ListReader(r io.Reader, name string, keepStrings []string, opts Options) ([]ListEntry, error) {
+	keep, err := util.CompileKeepRules(keepStrings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile --keep filters, error: %s", err)
+	}
+
+	var forced []aop
+	if opts.Format != "" {
+		if forced, err = formatOperations(opts.Format); err != nil {
+			return nil, err
+		}
+	}
+
+	stream, err := sniffStream(r, name, forced)
+	defer stream.Close()
+	if err != nil {
+		return nil, fmt.Errorf(`reading "%s" failed; error: %s`, name, err)
+	}
+	if err := stream.identified(name); err != nil {
+		return nil, err
+	}
+
+	if stream.Op >= 200 {
+		log.Infof("listing (%s) %s", stream.Format, name)
+		x := newExtractor(".", keep, opts)
+		x.listing = make([]ListEntry, 0)
+		if _, err := x.finish(walkStream(stream, x.extract)); err != nil {
+			return x.listing, fmt.Errorf(`reading (%s) "%s" failed; error: %s`, stream.Format, name, err)
+		}
+		return x.listing, x.res.Err()
+	}
+
+	// single files, either compressed or raw; the size and mode are unknown
+	le := ListEntry{Path: stream.NameNoExt, Type: entryTypeNames[typeRegular], Size: -1, Mode: "-"}
+	if stream.Op == opWriteRaw {
+		log.Infof("%s is not an archive", name)
+		le.Path = name
+	}
+	if outPath := keep.Rename(le.Path); outPath != name && outPath != "-" {
+		le.Selected = true
+		le.Output = outPath
+	}
+	return []ListEntry{le}, nil
+}
@@ -0,0 +1,114 @@
+package extract
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// paxXattrPrefix prefixes the PAX records which carry extended attributes
+const paxXattrPrefix = "SCHILY.xattr."
+
+// entryMetadata holds the file attributes recorded for an archive entry which
+// aren't applied when the file is created. Zero times are not restored.
+type entryMetadata struct {
+	ModTime    time.Time
+	AccessTime time.Time
+	HasOwner   bool
+	Uid        int
+	Gid        int
+	Xattrs     map[string]string
+}
+
+// paxXattrs returns the extended attributes found in the given PAX records
+func paxXattrs(records map[string]string) map[string]string {
+	xattrs := make(map[string]string)
+	for key, value := range records {
+		if strings.HasPrefix(key, paxXattrPrefix) {
+			xattrs[strings.TrimPrefix(key, paxXattrPrefix)] = value
+		}
+	}
+	return xattrs
+}
+
+// deferredDir is a directory whose metadata is applied after its contents
+// have been extracted
+type deferredDir struct {
+	Path string
+	Meta entryMetadata
+}
+
+// metadataWriter restores entry metadata on extracted files. Directory
+// metadata is deferred until finish is called because creating the files
+// within a directory changes its modification time. Failures are logged but
+// don't stop the extraction.
+type metadataWriter struct {
+	opts Options
+	dirs []deferredDir
+}
+
+// newMetadataWriter returns a metadataWriter which restores the attributes
+// enabled in the given options
+func newMetadataWriter(opts Options) *metadataWriter {
+	if opts.SameOwner && os.Geteuid() != 0 {
+		log.Warnf("not restoring file ownership; that requires running as root")
+		opts.SameOwner = false
+	}
+	return &metadataWriter{opts: opts}
+}
+
+// apply restores the given metadata on the file at the given path. Symlinks
+// are updated without following them.
+func (m *metadataWriter) apply(path string, meta entryMetadata, symlink bool) {
+	if m.opts.SameOwner && meta.HasOwner {
+		if err := os.Lchown(path, meta.Uid, meta.Gid); err != nil {
+			log.Warnf("%s: restoring ownership failed; error: %s", path, err)
+		}
+	}
+	if m.opts.Xattrs && len(meta.Xattrs) > 0 {
+		// apply in a stable order so failures are reproducible
+		names := make([]string, 0, len(meta.Xattrs))
+		for name := range meta.Xattrs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := lsetxattr(path, name, []byte(meta.Xattrs[name])); err != nil {
+				log.Warnf("%s: restoring extended attribute %s failed; error: %s", path, name, err)
+			}
+		}
+	}
+	if meta.ModTime.IsZero() {
+		return
+	}
+	atime := meta.AccessTime
+	if atime.IsZero() {
+		atime = meta.ModTime
+	}
+	var err error
+	if symlink {
+		err = lchtimes(path, atime, meta.ModTime)
+	} else {
+		err = os.Chtimes(path, atime, meta.ModTime)
+	}
+	if err != nil {
+		log.Warnf("%s: restoring modification time failed; error: %s", path, err)
+	}
+}
+
+// deferDir records metadata to be applied to the directory at the given path
+// once extraction has finished
+func (m *metadataWriter) deferDir(path string, meta entryMetadata) {
+	m.dirs = append(m.dirs, deferredDir{Path: path, Meta: meta})
+}
+
+// finish applies the deferred directory metadata, deepest directories first
+func (m *metadataWriter) finish() {
+	for i := len(m.dirs) - 1; i >= 0; i-- {
+		m.apply(m.dirs[i].Path, m.dirs[i].Meta, false)
+	}
+	m.dirs = nil
+}
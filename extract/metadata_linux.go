@@ -0,0 +1,22 @@
+package extract
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// lchtimes sets the access and modification times of the given path without
+// following symlinks
+func lchtimes(path string, atime time.Time, mtime time.Time) error {
+	return unix.Lutimes(path, []unix.Timeval{
+		unix.NsecToTimeval(atime.UnixNano()),
+		unix.NsecToTimeval(mtime.UnixNano()),
+	})
+}
+
+// lsetxattr sets an extended attribute on the given path without following
+// symlinks
+func lsetxattr(path string, name string, value []byte) error {
+	return unix.Lsetxattr(path, name, value, 0)
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package extract
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// lchtimes would set the times of a symlink, which isn't supported on this
+// platform so symlink times are left alone
+func lchtimes(path string, atime time.Time, mtime time.Time) error {
+	return nil
+}
+
+// lsetxattr would set an extended attribute, which isn't supported on this
+// platform
+func lsetxattr(path string, name string, value []byte) error {
+	return fmt.Errorf("extended attributes are not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,83 @@
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/backplane/ghlatest/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// nested examines a regular file entry which the keep rules selected and, if
+// it is an archive, extracts its contents in place of the archive itself.
+// Nested archives are identified by their magic bytes, with the strategies
+// filename extensions as a fallback. Selecting a nested archive selects all
+// of its entries, they are named relative to the directory of the archive's
+// output path (outPath). A single compressed file is replaced by its
+// decompressed contents, under the output path with the compression
+// extension removed, or under the output path itself if a keep rule renamed
+// it.
+//
+// The returned bool reports whether the entry was handled. If it wasn't, the
+// entry's Open function now returns the returned stream, which the caller
+// must close, and the entry is renamed to its output path if it was
+// decompressed.
+func (x *extractor) nested(e *entry, outPath string) (bool, *sniffedStream, error) {
+	rc, err := e.Open()
+	if err != nil {
+		return true, nil, x.fail(e, "", fmt.Errorf("opening source contents failed; error: %s", err))
+	}
+	stream, err := sniffStream(rc, e.Name, nil)
+	// the sniffed bytes are buffered, so the entry must now be read from the stream
+	e.Open = func() (io.ReadCloser, error) { return stream, nil }
+	if err != nil {
+		stream.Close()
+		return true, nil, x.fail(e, "", fmt.Errorf("reading source contents failed; error: %s", err))
+	}
+
+	if stream.Op < 200 {
+		if stream.Op == opWriteSingleton {
+			name := outPath
+			if name == util.NormalizeFilePath(e.Name) {
+				name = util.NormalizeFilePath(stream.NameNoExt)
+			}
+			log.Debugf("%s: decompressing (%s) to %s", e.Name, stream.Format, name)
+			e.Name = name
+			e.Size = -1
+		}
+		return false, stream, nil
+	}
+	defer stream.Close()
+
+	if x.listing != nil {
+		log.Infof("listing nested (%s) %s", stream.Format, e.Name)
+	} else {
+		log.Infof("extracting nested (%s) %s", stream.Format, e.Name)
+	}
+
+	// nested entries are placed alongside the archive which contained them
+	dir := path.Dir(outPath)
+	fn := func(ne *entry) error {
+		ne.Name = path.Join(dir, util.NormalizeFilePath(ne.Name))
+		if ne.Type == typeHardlink {
+			ne.Linkname = path.Join(dir, util.NormalizeFilePath(ne.Linkname))
+		}
+		return x.extract(ne)
+	}
+
+	x.depth++
+	restore := x.keepAll()
+	err = walkStream(stream, fn)
+	restore()
+	x.depth--
+
+	if errors.Is(err, errStopExtraction) {
+		return true, nil, err
+	}
+	if err != nil {
+		return true, nil, x.fail(e, "", fmt.Errorf("reading nested (%s) archive failed; error: %s", stream.Format, err))
+	}
+	return true, nil, nil
+}
@@ -0,0 +1,42 @@
+package extract
+
+import (
+	"errors"
+	"io/fs"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// errNodesUnsupported is returned when FIFOs and device nodes can't be
+// created on the current platform
+var errNodesUnsupported = errors.New("creating FIFOs and device nodes is not supported on this platform")
+
+// newFifo creates a named pipe at the given path with the given permissions
+func newFifo(path string, perm fs.FileMode, overwrite bool) error {
+	if err := removeForOverwrite(path, overwrite); err != nil {
+		return err
+	}
+	if err := mkfifo(path, perm); err != nil {
+		return err
+	}
+	log.Infof("created fifo:\"%s\"; mode:%#o", path, perm)
+	return nil
+}
+
+// newDevice creates a character or block device node at the given path with
+// the given permissions and device numbers. This typically requires root
+// privileges, callers can check for [fs.ErrPermission].
+func newDevice(path string, perm fs.FileMode, char bool, major int64, minor int64, overwrite bool) error {
+	if err := removeForOverwrite(path, overwrite); err != nil {
+		return err
+	}
+	if err := mknod(path, perm, char, major, minor); err != nil {
+		return err
+	}
+	kind := "block"
+	if char {
+		kind = "char"
+	}
+	log.Infof("created %s device:\"%s\"; mode:%#o; device:%d,%d", kind, path, perm, major, minor)
+	return nil
+}
@@ -0,0 +1,9 @@
+package extract
+
+import "golang.org/x/sys/unix"
+
+// mkdev returns the device number of the given major and minor numbers in the
+// form mknod takes, which is 64 bits wide on freebsd
+func mkdev(major int64, minor int64) uint64 {
+	return unix.Mkdev(uint32(major), uint32(minor))
+}
@@ -0,0 +1,11 @@
+//go:build unix && !freebsd
+
+package extract
+
+import "golang.org/x/sys/unix"
+
+// mkdev returns the device number of the given major and minor numbers in the
+// form mknod takes
+func mkdev(major int64, minor int64) int {
+	return int(unix.Mkdev(uint32(major), uint32(minor)))
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package extract
+
+import (
+	"io/fs"
+)
+
+// mkfifo isn't supported on this platform
+func mkfifo(path string, perm fs.FileMode) error {
+	return errNodesUnsupported
+}
+
+// mknod isn't supported on this platform
+func mknod(path string, perm fs.FileMode, char bool, major int64, minor int64) error {
+	return errNodesUnsupported
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package extract
+
+import (
+	"io/fs"
+
+	"golang.org/x/sys/unix"
+)
+
+// mkfifo wraps the mkfifo system call
+func mkfifo(path string, perm fs.FileMode) error {
+	return unix.Mkfifo(path, uint32(perm))
+}
+
+// mknod wraps the mknod system call for character and block devices
+func mknod(path string, perm fs.FileMode, char bool, major int64, minor int64) error {
+	mode := uint32(perm) | unix.S_IFBLK
+	if char {
+		mode = uint32(perm) | unix.S_IFCHR
+	}
+	return unix.Mknod(path, mode, mkdev(major, minor))
+}
@@ -0,0 +1,129 @@
+package extract
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/backplane/ghlatest/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// ExtractReader extracts the contents of the archive read from r, such as an
+// HTTP response body or stdin, without first writing the archive to disk. The
+// name argument is the archive's (file) name ("-" for stdin), it's used when
+// the format can't be identified by its magic bytes (or given in
+// opts.Format) and to name single-file output, which is written with the
+// given mode. Tar, cpio, ar, deb and rpm archives (which may be compressed)
+// are extracted as they're read, zip and 7z archives need random access and
+// are first spooled to a temporary file. Executables are written as they are,
+// as with ExtractFile it's an error if the format can't be identified. The
+// keepStrings and opts arguments and the returned Result work as they do for
+// ExtractFile.
+func ExtractReader(r io.Reader, name string, mode fs.FileMode, keepStrings []string, opts Options) (*Result, error) {
+	res, err := extractReader(r, name, mode, keepStrings, opts)
+	return checkExecutables(res, err, keepStrings, opts)
+}
+
+// extractReader implements ExtractReader
+func extractReader(r io.Reader, name string, mode fs.FileMode, keepStrings []string, opts Options) (*Result, error) {
+	keep, err := util.CompileKeepRules(keepStrings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile --keep filters, error: %s", err)
+	}
+
+	var forced []aop
+	if opts.Format != "" {
+		if forced, err = formatOperations(opts.Format); err != nil {
+			return nil, err
+		}
+	}
+
+	stream, err := sniffStream(r, name, forced)
+	defer stream.Close()
+	if err != nil {
+		return nil, fmt.Errorf(`reading "%s" failed; error: %s`, name, err)
+	}
+	if err := stream.identified(name); err != nil {
+		return nil, err
+	}
+	if stream.Format != "" {
+		log.Debugf("detected %s format for %s", stream.Format, name)
+	}
+
+	switch stream.Op {
+	case opWriteSingleton:
+		outputPath := keep.Rename(stream.NameNoExt)
+		if outputPath == name {
+			return nil, fmt.Errorf(`can't choose an output name for the decompressed contents of "%s"; use a --keep rename rule`, name)
+		}
+		outputPath = inOutputDir(opts.OutputDir, outputPath)
+		log.Infof("decompressing (%s) %s", stream.Format, name)
+		return writeSingleFile(stream, stream.NameNoExt, outputPath, mode, opts)
+	case opWriteRaw:
+		// an executable, the stream is written as it is
+		outputPath := keep.Rename(name)
+		if outputPath == "-" {
+			return nil, fmt.Errorf(`can't choose an output name for the contents of stdin; use a --keep rename rule`)
+		}
+		outputPath = inOutputDir(opts.OutputDir, outputPath)
+		return writeSingleFile(stream, name, outputPath, mode, opts)
+	}
+
+	log.Infof("extracting (%s) %s", stream.Format, name)
+	outputDir := "."
+	if opts.OutputDir != "" {
+		outputDir = opts.OutputDir
+	}
+	x := newExtractor(outputDir, keep, opts)
+	res, err := x.finish(walkStream(stream, x.extract))
+	return archiveResult(res, err, stream.Format, name)
+}
+
+// streamWalkers are the walkers for the archive formats which can be read
+// sequentially, archives in these formats are extracted as they're read
+var streamWalkers = map[aop]func(io.Reader, walkFunc) error{
+	opUnar:   walkAr,
+	opUncpio: walkCpio,
+	opUndeb:  walkDeb,
+	opUnrpm:  walkRpm,
+	opUntar:  walkTar,
+}
+
+// fileWalkers are the walkers for the archive formats which need random
+// access, streams in these formats are spooled to a temporary file
+var fileWalkers = map[aop]func(io.ReaderAt, int64, walkFunc) error{
+	opUn7z:  walk7z,
+	opUnzip: walkZip,
+}
+
+// walkStream calls fn for each entry of the given multi-file archive stream.
+// The formats which can't be read sequentially are first copied to a
+// temporary file.
+func walkStream(stream *sniffedStream, fn walkFunc) error {
+	if walk, ok := streamWalkers[stream.Op]; ok {
+		return walk(stream, fn)
+	}
+	if walk, ok := fileWalkers[stream.Op]; ok {
+		return walkSpooled(walk, stream, fn)
+	}
+	panic(fmt.Sprintf("Encountered unhandled archive operation %d (%s)", stream.Op, archiveOpNames[stream.Op]))
+}
+
+// walkSpooled copies the given archive stream to a temporary file and walks it
+func walkSpooled(walk func(io.ReaderAt, int64, walkFunc) error, r io.Reader, fn walkFunc) error {
+	f, err := os.CreateTemp("", "ghlatest-spool-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	size, err := io.Copy(f, r)
+	if err != nil {
+		return fmt.Errorf("spooling to temporary file failed; error: %s", err)
+	}
+	log.Debugf("spooled archive stream to %s; bytes:%d", f.Name(), size)
+	return walk(f, size, fn)
+}
@@ -0,0 +1,129 @@
+package extract
+
+import (
+	"errors"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrorMode selects what happens when an archive entry can't be extracted
+type ErrorMode int
+
+const (
+	// StopOnError abandons the rest of the archive after the first failure
+	StopOnError ErrorMode = iota
+	// SkipOnError records the failure and continues with the next entry
+	SkipOnError
+)
+
+// errorModeNames maps the ErrorMode values to their command-line names
+var errorModeNames = map[ErrorMode]string{
+	StopOnError: "stop",
+	SkipOnError: "skip",
+}
+
+// String returns the command-line name of the ErrorMode
+func (m ErrorMode) String() string {
+	return errorModeNames[m]
+}
+
+// ParseErrorMode returns the ErrorMode with the given command-line name
+func ParseErrorMode(name string) (ErrorMode, error) {
+	for mode, modeName := range errorModeNames {
+		if modeName == name {
+			return mode, nil
+		}
+	}
+	return StopOnError, fmt.Errorf("unknown error mode \"%s\", it should be \"stop\" or \"skip\"", name)
+}
+
+// errStopExtraction is returned by the extractor to stop walking an archive
+// after a failure when the ErrorMode is StopOnError
+var errStopExtraction = errors.New("extraction stopped")
+
+// EntryResult describes what happened to a single entry of an archive
+type EntryResult struct {
+	Name       string `json:"name"`                 // path of the entry within the archive
+	Path       string `json:"path,omitempty"`       // path the entry was (or would have been) written to
+	Reason     string `json:"reason,omitempty"`     // why the entry was skipped or failed
+	Action     string `json:"action,omitempty"`     // for dry runs: create, overwrite or exists
+	Executable bool   `json:"executable,omitempty"` // with Options.Bin: the file was found to be an executable
+}
+
+// Result lists the entries of an archive which were extracted, skipped or
+// which failed to extract
+type Result struct {
+	Extracted []EntryResult `json:"extracted"`
+	Skipped   []EntryResult `json:"skipped"`
+	Failed    []EntryResult `json:"failed"`
+}
+
+// newResult returns an empty Result
+func newResult() *Result {
+	return &Result{
+		Extracted: make([]EntryResult, 0),
+		Skipped:   make([]EntryResult, 0),
+		Failed:    make([]EntryResult, 0),
+	}
+}
+
+// Executables returns the output paths of the extracted entries which were
+// found to be executables, this requires Options.Bin
+func (r *Result) Executables() []string {
+	paths := make([]string, 0)
+	for _, e := range r.Extracted {
+		if e.Executable {
+			paths = append(paths, e.Path)
+		}
+	}
+	return paths
+}
+
+// extracted records an entry which was written to the given path
+func (r *Result) extracted(name string, path string) {
+	r.Extracted = append(r.Extracted, EntryResult{Name: name, Path: path})
+}
+
+// executable records an entry which was written to the given path and found
+// to be an executable
+func (r *Result) executable(name string, path string) {
+	r.Extracted = append(r.Extracted, EntryResult{Name: name, Path: path, Executable: true})
+}
+
+// planned records an entry which a dry run found would be written to the
+// given path
+func (r *Result) planned(name string, path string, action string) {
+	r.Extracted = append(r.Extracted, EntryResult{Name: name, Path: path, Action: action})
+}
+
+// skipped records an entry which was deliberately not extracted
+func (r *Result) skipped(name string, path string, reason string) {
+	r.Skipped = append(r.Skipped, EntryResult{Name: name, Path: path, Reason: reason})
+}
+
+// failed records an entry which couldn't be extracted
+func (r *Result) failed(name string, path string, reason string) {
+	r.Failed = append(r.Failed, EntryResult{Name: name, Path: path, Reason: reason})
+}
+
+// Err returns an error describing the failed entries, or nil if there were
+// none
+func (r *Result) Err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d archive entries failed to extract", len(r.Failed))
+}
+
+// LogSummary logs the number of extracted, skipped and failed entries, along
+// with the reasons for each failure
+func (r *Result) LogSummary() {
+	log.Infof("extraction summary: %d extracted, %d skipped, %d failed", len(r.Extracted), len(r.Skipped), len(r.Failed))
+	for _, e := range r.Skipped {
+		log.Debugf("skipped %s: %s", e.Name, e.Reason)
+	}
+	for _, e := range r.Failed {
+		log.Errorf("failed %s: %s", e.Name, e.Reason)
+	}
+}
@@ -0,0 +1,43 @@
+package extract
+
+import (
+	"path/filepath"
+
+	"github.com/backplane/ghlatest/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// selectOutputPath normalizes the given archive entry name and applies the
+// KeepSet to it. If the entry is selected, any missing parent directories of
+// the resulting output path (within the given outputDir) are created and the
+// output path is returned along with true. It's an error if the output path
+// resolves, through the symlinks already on disk, to a location outside of
+// outputDir. Entries which aren't selected return false.
+func selectOutputPath(outputDir string, keep util.KeepSet, name string) (string, bool, error) {
+	filePath := util.NormalizeFilePath(name)
+	outPath, selected := keep.Select(filePath)
+	if !selected {
+		log.Debugf("Skipping %s", filePath)
+		return "", false, nil
+	}
+	if outPath != filePath {
+		log.Debugf("%s: renaming to %s", filePath, outPath)
+	}
+	outPath = inOutputDir(outputDir, outPath)
+	if err := checkWithinRoot(outputDir, outPath); err != nil {
+		return outPath, true, err
+	}
+	if err := util.NewParentDirectories(outPath, 0755); err != nil {
+		return outPath, true, err
+	}
+	return outPath, true, nil
+}
+
+// inOutputDir returns the given output path within the given output
+// directory, paths are left as they are for the current directory
+func inOutputDir(outputDir string, outPath string) string {
+	if outputDir == "" || outputDir == "." {
+		return outPath
+	}
+	return filepath.Join(outputDir, outPath)
+}
@@ -0,0 +1,316 @@
+package extract
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// sniffLen is the number of leading bytes examined when identifying a format,
+// it is large enough to contain the magic field of a tar header
+const sniffLen = 512
+
+// magicStrategy identifies a file format by the bytes found at a fixed offset
+// from the start of the file. A nil Operations list indicates a recognized
+// format which can't be extracted.
+type magicStrategy struct {
+	Name       string
+	Offset     int
+	Magic      []byte
+	Operations []aop
+}
+
+// compressionMagics identify stream compression formats, which are followed
+// by another round of sniffing on the decompressed data
+var compressionMagics = []magicStrategy{
+	{"gzip", 0, []byte{0x1f, 0x8b}, []aop{opUngzip}},
+	{"bzip2", 0, []byte("BZh"), []aop{opUnbzip2}},
+	{"xz", 0, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, []aop{opUnxz}},
+	{"zstd", 0, []byte{0x28, 0xb5, 0x2f, 0xfd}, []aop{opUnzstd}},
+	{"lz4", 0, []byte{0x04, 0x22, 0x4d, 0x18}, []aop{opUnlz4}},
+}
+
+// archiveMagics identify multi-file archive formats and raw executables
+var archiveMagics = []magicStrategy{
+	{"zip", 0, []byte("PK\x03\x04"), []aop{opUnzip}},
+	{"zip", 0, []byte("PK\x05\x06"), []aop{opUnzip}}, // empty archive
+	{"7z", 0, []byte{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}, []aop{opUn7z}},
+	{"deb", 0, []byte(arMagic + "debian-binary"), []aop{opUndeb}},
+	{"rpm", 0, []byte{0xed, 0xab, 0xee, 0xdb}, []aop{opUnrpm}},
+	{"tar", 257, []byte("ustar"), []aop{opUntar}},
+	{"ar", 0, []byte(arMagic), []aop{opUnar}},
+	{"cpio", 0, []byte("070701"), []aop{opUncpio}},
+	{"cpio", 0, []byte("070702"), []aop{opUncpio}},
+	{"cpio", 0, []byte("070707"), []aop{opUncpio}},
+	{"ELF executable", 0, []byte{0x7f, 'E', 'L', 'F'}, []aop{opWriteRaw}},
+	{"Mach-O executable", 0, []byte{0xfe, 0xed, 0xfa, 0xce}, []aop{opWriteRaw}},
+	{"Mach-O executable", 0, []byte{0xfe, 0xed, 0xfa, 0xcf}, []aop{opWriteRaw}},
+	{"Mach-O executable", 0, []byte{0xce, 0xfa, 0xed, 0xfe}, []aop{opWriteRaw}},
+	{"Mach-O executable", 0, []byte{0xcf, 0xfa, 0xed, 0xfe}, []aop{opWriteRaw}},
+	{"Mach-O universal executable", 0, []byte{0xca, 0xfe, 0xba, 0xbe}, []aop{opWriteRaw}},
+	{"PE executable", 0, []byte("MZ"), []aop{opWriteRaw}},
+}
+
+// decompressors maps the decompression operations to constructors for their
+// stream readers
+var decompressors = map[aop]func(io.Reader) (io.ReadCloser, error){
+	opUnbzip2: newBzip2Reader,
+	opUngzip:  newGzipReader,
+	opUnlz4:   newLz4Reader,
+	opUnxz:    newXzReader,
+	opUnzstd:  newZstdReader,
+}
+
+// matchMagic returns the first of the given strategies whose magic bytes are
+// present in the given header
+func matchMagic(header []byte, magics []magicStrategy) *magicStrategy {
+	for i, m := range magics {
+		end := m.Offset + len(m.Magic)
+		if len(header) >= end && bytes.Equal(header[m.Offset:end], m.Magic) {
+			return &magics[i]
+		}
+	}
+	return nil
+}
+
+// readHeader returns up to sniffLen leading bytes from the given reader
+func readHeader(r io.Reader) ([]byte, error) {
+	header := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return header[:n], nil
+}
+
+// sniffOperations identifies the format of the archive by the magic bytes at
+// the start of its contents (and, for compressed data, the start of the
+// decompressed contents) and returns the name of the format and the
+// operations needed to extract it. The byName operations (selected by
+// filename extension) serve as a tiebreaker when the contents aren't
+// conclusive. A nil operations list is returned if the format couldn't be
+// identified. The read position of the archive's file handle is not changed.
+func (a *Archive) sniffOperations(byName []aop) (string, []aop, error) {
+	src := io.NewSectionReader(a.FileHandle, 0, a.FileStats.Size())
+	header, err := readHeader(src)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if m := matchMagic(header, archiveMagics); m != nil {
+		return m.Name, m.Operations, nil
+	}
+
+	m := matchMagic(header, compressionMagics)
+	if m == nil {
+		// the contents are inconclusive, fall back to the filename
+		return "", byName, nil
+	}
+	if m.Operations == nil {
+		return m.Name, nil, nil
+	}
+
+	// peek at the decompressed contents to choose between tar and singleton
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return "", nil, err
+	}
+	dr, err := decompressors[m.Operations[0]](src)
+	if err != nil {
+		return "", nil, err
+	}
+	defer dr.Close()
+	innerHeader, err := readHeader(dr)
+	if err != nil {
+		log.Debugf("sniffing decompressed (%s) contents failed; error: %s", m.Name, err)
+		innerHeader = nil
+	}
+
+	// only the stream-oriented archive formats can be read from the
+	// decompressor, anything else is treated as a single compressed file
+	var inner []aop
+	switch im := matchMagic(innerHeader, archiveMagics); {
+	case im != nil && streamable(im.Operations[0]):
+		return im.Name + "+" + m.Name, append([]aop{m.Operations[0]}, im.Operations...), nil
+	case im == nil && len(byName) > 1 && streamable(byName[len(byName)-1]):
+		// e.g. pre-POSIX tar archives lack the ustar magic
+		inner = []aop{byName[len(byName)-1]}
+	default:
+		inner = []aop{opWriteSingleton}
+	}
+	return m.Name, append([]aop{m.Operations[0]}, inner...), nil
+}
+
+// streamable reports whether the given multi-file writer reads its archive
+// sequentially, which allows it to follow a decompressor
+func streamable(op aop) bool {
+	switch op {
+	case opUnar, opUncpio, opUntar:
+		return true
+	}
+	return false
+}
+
+// decompressStream identifies the compression format of the given stream by
+// its magic bytes and returns a reader for the decompressed contents along
+// with the name of the compression format. Uncompressed streams are returned
+// as-is with an empty format name.
+func decompressStream(r io.Reader) (io.ReadCloser, string, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	header, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, "", err
+	}
+	m := matchMagic(header, compressionMagics)
+	if m == nil {
+		return io.NopCloser(br), "", nil
+	}
+	dr, err := decompressors[m.Operations[0]](br)
+	if err != nil {
+		return nil, m.Name, err
+	}
+	return dr, m.Name, nil
+}
+
+// sniffedStream is a stream whose format has been identified by sniffStream,
+// reading from it returns the (decompressed) contents. Closing it closes each
+// of the underlying readers, it is safe to close more than once.
+type sniffedStream struct {
+	io.Reader
+	Op        aop    // multi-file writer, or opWriteSingleton/opWriteRaw for single files
+	Format    string // name of the identified format(s), if any
+	NameNoExt string // the stream's name with any recognized filename extensions removed
+	closers   []io.Closer
+}
+
+// Close closes the underlying readers, most recently opened first
+func (s *sniffedStream) Close() error {
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		s.closers[i].Close()
+	}
+	s.closers = nil
+	return nil
+}
+
+// identified returns an error if the stream's format couldn't be identified
+// by its contents or its name. Entries of archives in an unknown format are
+// just files, but at the top level such streams are refused, as files are.
+func (s *sniffedStream) identified(name string) error {
+	if s.Op == opWriteRaw && s.Format == "" {
+		return fmt.Errorf(`don't know how to extract "%s"`, name)
+	}
+	return nil
+}
+
+// peek returns up to sniffLen leading bytes from the given reader without
+// consuming them
+func peek(br *bufio.Reader) ([]byte, error) {
+	header, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	return header, nil
+}
+
+// sniffStream identifies the format of the given stream, which has the given
+// name, by the magic bytes at the start of its contents (and, for compressed
+// data, the start of the decompressed contents). The strategies filename
+// extensions serve as a fallback when the contents aren't conclusive.
+// Compressed multi-file archives are decompressed as they're read, other
+// compressed data is identified as a single compressed file. If the forced
+// operations are given they're used instead of identifying the format. If r
+// is also an io.Closer it is closed along with the returned stream, which is
+// returned (and must be closed) even if an error occurs.
+func sniffStream(r io.Reader, name string, forced []aop) (*sniffedStream, error) {
+	var byName []aop
+	s := &sniffedStream{NameNoExt: name}
+	for _, strategy := range strategies {
+		if strategy.FilenameRegexp.MatchString(name) {
+			byName = strategy.Operations
+			s.NameNoExt = strategy.FilenameRegexp.ReplaceAllString(name, "")
+			break
+		}
+	}
+	if c, ok := r.(io.Closer); ok {
+		s.closers = append(s.closers, c)
+	}
+	if forced != nil {
+		return s, s.force(r, forced)
+	}
+
+	br := bufio.NewReaderSize(r, sniffLen)
+	s.Reader = br
+	header, err := peek(br)
+	if err != nil {
+		return s, err
+	}
+
+	var compression string
+	if m := matchMagic(header, compressionMagics); m != nil {
+		dr, err := decompressors[m.Operations[0]](br)
+		if err != nil {
+			return s, fmt.Errorf("decompressing (%s) failed; error: %s", m.Name, err)
+		}
+		compression = m.Name
+		s.closers = append(s.closers, dr)
+		br = bufio.NewReaderSize(dr, sniffLen)
+		s.Reader = br
+		if header, err = peek(br); err != nil {
+			return s, fmt.Errorf("decompressing (%s) failed; error: %s", m.Name, err)
+		}
+	}
+
+	m := matchMagic(header, archiveMagics)
+	switch {
+	case m != nil && m.Operations[0] >= 200:
+		s.Op, s.Format = m.Operations[0], m.Name
+	case m == nil && len(byName) > 0 && byName[len(byName)-1] >= 200:
+		// e.g. pre-POSIX tar archives lack the ustar magic
+		s.Op = byName[len(byName)-1]
+		s.Format = archiveOpNames[s.Op]
+	case compression != "":
+		s.Op, s.Format = opWriteSingleton, compression
+		return s, nil
+	default:
+		s.Op = opWriteRaw
+		if m != nil {
+			s.Format = m.Name
+		}
+		return s, nil
+	}
+	if compression != "" {
+		s.Format += "+" + compression
+	}
+	return s, nil
+}
+
+// force sets up the stream to read r with the given operations, rather than
+// those identified from its contents
+func (s *sniffedStream) force(r io.Reader, operations []aop) error {
+	s.Reader = r
+	var compression string
+	for _, op := range operations {
+		if op >= 100 {
+			s.Op = op
+			break
+		}
+		dr, err := decompressors[op](s.Reader)
+		if err != nil {
+			return fmt.Errorf("decompressing (%s) failed; error: %s", archiveOpNames[op], err)
+		}
+		compression = archiveOpNames[op]
+		s.closers = append(s.closers, dr)
+		s.Reader = dr
+	}
+	switch {
+	case s.Op >= 200 && compression != "":
+		s.Format = archiveOpNames[s.Op] + "+" + compression
+	case s.Op >= 200:
+		s.Format = archiveOpNames[s.Op]
+	default:
+		s.Format = compression
+	}
+	return nil
+}
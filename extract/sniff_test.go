@@ -0,0 +1,159 @@
+package extract
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tarBytes returns a tar archive containing a single file, with the ustar
+// magic unless oldStyle is set
+func tarBytes(t *testing.T, oldStyle bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	hdr := &tar.Header{Name: "tool", Typeflag: tar.TypeReg, Mode: 0755, Size: 5}
+	if err := tw.WriteHeader(hdr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte("tool\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	if oldStyle {
+		// blank out the magic and version, as in a pre-POSIX archive
+		copy(b[257:265], make([]byte, 8))
+	}
+	return b
+}
+
+// gzipBytes returns the given data gzip compressed
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// zipBytes returns a zip archive containing a single file
+func zipBytes(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("tool\n"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// sniffCases are the contents and names of archives along with the final
+// operation and format they should be identified as
+func sniffCases(t *testing.T) []struct {
+	name     string
+	contents []byte
+	op       aop
+	format   string
+} {
+	tarball := tarBytes(t, false)
+	return []struct {
+		name     string
+		contents []byte
+		op       aop
+		format   string
+	}{
+		{"tool.tar", tarball, opUntar, "tar"},
+		{"tool.bin", tarball, opUntar, "tar"},
+		{"tool.tar.gz", gzipBytes(t, tarball), opUntar, "tar+gzip"},
+		// the contents win over a misleading extension
+		{"tool.zip", gzipBytes(t, tarball), opUntar, "tar+gzip"},
+		{"tool.tar.gz", zipBytes(t), opUnzip, "zip"},
+		// pre-POSIX tar archives are only recognized by their extension
+		{"tool.tar.gz", gzipBytes(t, tarBytes(t, true)), opUntar, "gzip"},
+		{"tool.gz", gzipBytes(t, []byte("tool\n")), opWriteSingleton, "gzip"},
+		{"tool", []byte("\x7fELF\x02\x01\x01\x00"), opWriteRaw, "ELF executable"},
+		{"tool.exe", []byte("MZ\x90\x00"), opWriteRaw, "PE executable"},
+	}
+}
+
+func TestSniffOperations(t *testing.T) {
+	dir := t.TempDir()
+	for i, tc := range sniffCases(t) {
+		path := filepath.Join(dir, string(rune('a'+i))+"-"+tc.name)
+		if err := os.WriteFile(path, tc.contents, 0644); err != nil {
+			t.Fatal(err)
+		}
+		a, format, op, err := prepareArchive(path, Options{Name: tc.name})
+		if err != nil {
+			t.Errorf("%s (%s): %s", tc.name, tc.format, err)
+			continue
+		}
+		a.Close()
+		if op != tc.op || format != tc.format {
+			t.Errorf("%s (%s): identified as %s (%s)", tc.name, tc.format, archiveOpNames[op], format)
+		}
+	}
+}
+
+func TestSniffStream(t *testing.T) {
+	for _, tc := range sniffCases(t) {
+		s, err := sniffStream(bytes.NewReader(tc.contents), tc.name, nil)
+		s.Close()
+		if err != nil {
+			t.Errorf("%s (%s): %s", tc.name, tc.format, err)
+			continue
+		}
+		// the stream reports a pre-POSIX tar by its archive format
+		format := tc.format
+		if format == "gzip" && tc.op == opUntar {
+			format = "tar+gzip"
+		}
+		if s.Op != tc.op || s.Format != format {
+			t.Errorf("%s (%s): identified as %s (%s)", tc.name, format, archiveOpNames[s.Op], s.Format)
+		}
+	}
+}
+
+func TestSniffUnknown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tool.dat")
+	if err := os.WriteFile(path, []byte("neither an archive nor an executable\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if a, _, _, err := prepareArchive(path, Options{}); err == nil {
+		a.Close()
+		t.Errorf("expected an error for contents which aren't recognized")
+	}
+}
+
+func TestExtractReaderUnknown(t *testing.T) {
+	contents := []byte("neither an archive nor an executable\n")
+	opts := Options{OutputDir: t.TempDir()}
+	if _, err := ExtractReader(bytes.NewReader(contents), "tool.dat", 0644, nil, opts); err == nil {
+		t.Errorf("expected an error for contents which aren't recognized")
+	}
+	if _, err := ListReader(bytes.NewReader(contents), "tool.dat", nil, opts); err == nil {
+		t.Errorf("expected an error listing contents which aren't recognized")
+	}
+	// executables are written as they are
+	res, err := ExtractReader(bytes.NewReader([]byte("\x7fELF\x02\x01\x01\x00")), "tool", 0755, nil, opts)
+	if err != nil || len(res.Extracted) != 1 {
+		t.Errorf("expected the executable to be written, got %+v, %v", res, err)
+	}
+}
@@ -2,14 +2,24 @@ package extract
 
 import (
 	"compress/bzip2"
+	"io"
 	"io/ioutil"
 )
 
+// newBzip2Reader returns a bzip2 decompression reader for the given source
+func newBzip2Reader(r io.Reader) (io.ReadCloser, error) {
+	// https://pkg.go.dev/compress/bzip2
+	return ioutil.NopCloser(bzip2.NewReader(r)), nil
+}
+
 // Unbzip2 enables bzip2 decompression of the Archive data. It creates a bzip2
 // stream reader for Archive.FileHandle on Archive.StreamHandle. Any errors
 // creating the reader will be returned.
 func (a *Archive) Unbzip2() error {
-	// https://pkg.go.dev/compress/bzip2
-	a.StreamHandle = ioutil.NopCloser(bzip2.NewReader(a.FileHandle))
+	bzr, err := newBzip2Reader(a.FileHandle)
+	if err != nil {
+		return err
+	}
+	a.StreamHandle = bzr
 	return nil
 }
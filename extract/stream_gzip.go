@@ -2,15 +2,21 @@ package extract
 
 import (
 	"compress/gzip"
+	"io"
 )
 
+// newGzipReader returns a gzip decompression reader for the given source
+func newGzipReader(r io.Reader) (io.ReadCloser, error) {
+	// https://pkg.go.dev/compress/gzip@go1.20.1#example-package-WriterReader
+	// Note the caller needs to close the reader
+	return gzip.NewReader(r)
+}
+
 // Ungzip enables gzip decompression of the Archive data. It creates a gzip stream
 // reader for Archive.FileHandle on Archive.StreamHandle. Any errors creating
 // the reader will be returned.
 func (a *Archive) Ungzip() error {
-	// https://pkg.go.dev/compress/gzip@go1.20.1#example-package-WriterReader
-	// Note the caller needs to close the reader
-	zr, err := gzip.NewReader(a.FileHandle)
+	zr, err := newGzipReader(a.FileHandle)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,27 @@
+package extract
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/pierrec/lz4/v4"
+)
+
+// newLz4Reader returns an lz4 (frame format) decompression reader for the
+// given source
+func newLz4Reader(r io.Reader) (io.ReadCloser, error) {
+	// https://pkg.go.dev/github.com/pierrec/lz4/v4
+	return ioutil.NopCloser(lz4.NewReader(r)), nil
+}
+
+// Unlz4 enables lz4 decompression of the Archive data. It creates an lz4
+// stream reader for Archive.FileHandle on Archive.StreamHandle. Any errors
+// creating the reader will be returned.
+func (a *Archive) Unlz4() error {
+	lzr, err := newLz4Reader(a.FileHandle)
+	if err != nil {
+		return err
+	}
+	a.StreamHandle = lzr
+	return nil
+}
@@ -1,20 +1,30 @@
 package extract
 
 import (
+	"io"
 	"io/ioutil"
 
 	"github.com/ulikunitz/xz"
 )
 
+// newXzReader returns an xz decompression reader for the given source
+func newXzReader(r io.Reader) (io.ReadCloser, error) {
+	// https://pkg.go.dev/github.com/ulikunitz/xz#section-readme
+	xzr, err := xz.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(xzr), nil
+}
+
 // Unxz enables xz decompression of the Archive data. It creates an xz stream
 // reader for Archive.FileHandle on Archive.StreamHandle. Any errors creating
 // the reader will be returned.
 func (a *Archive) Unxz() error {
-	// https://pkg.go.dev/github.com/ulikunitz/xz#section-readme
-	xzr, err := xz.NewReader(a.FileHandle)
+	xzr, err := newXzReader(a.FileHandle)
 	if err != nil {
 		return err
 	}
-	a.StreamHandle = ioutil.NopCloser(xzr)
+	a.StreamHandle = xzr
 	return err
 }
@@ -0,0 +1,29 @@
+package extract
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// newZstdReader returns a zstd decompression reader for the given source
+func newZstdReader(r io.Reader) (io.ReadCloser, error) {
+	// https://pkg.go.dev/github.com/klauspost/compress/zstd#section-readme
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return zr.IOReadCloser(), nil
+}
+
+// Unzstd enables zstd decompression of the Archive data. It creates a zstd
+// stream reader for Archive.FileHandle on Archive.StreamHandle. Any errors
+// creating the reader will be returned.
+func (a *Archive) Unzstd() error {
+	zr, err := newZstdReader(a.FileHandle)
+	if err != nil {
+		return err
+	}
+	a.StreamHandle = zr
+	return nil
+}
@@ -14,20 +14,84 @@ func latestReleasedAssets(owner string, repo string, filters []*regexp.Regexp, s
 	// latest release, optionally filtering results that match the given
 	// filter regexp
 
-	var result []string
-
 	log.Debugf("Listing %s/%s with %d filters: %v", owner, repo, len(filters), filters)
 	// talk to the github api and get info on the latest release
-	client := github.NewClient(nil)
-	ctx := context.Background()
-	release, _, err := client.Repositories.GetLatestRelease(ctx, owner, repo)
+	release, err := latestRelease(owner, repo)
 	if err != nil {
-		log.Fatalf("Repositories.GetLatestRelease returned error: %v\n", err)
+		log.Fatalf("%s\n", err)
 	}
+	return releaseAssetURLs(release, filters, source)
+}
+
+// releaseAssetURLs returns the download URLs of the assets of the given
+// release which match the given filters, or the URL of its source tarball
+func releaseAssetURLs(release *github.RepositoryRelease, filters []*regexp.Regexp, source bool) []string {
+	var result []string
 	if source {
 		result = append(result, release.GetTarballURL())
 		return result
 	}
+	for _, asset := range filterAssets(release, filters) {
+		result = append(result, asset.GetBrowserDownloadURL())
+	}
+
+	return result
+}
+
+// latestRelease asks the github api for the latest release of the given repo
+func latestRelease(owner string, repo string) (*github.RepositoryRelease, error) {
+	client := github.NewClient(nil)
+	ctx := context.Background()
+	release, _, err := client.Repositories.GetLatestRelease(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("Repositories.GetLatestRelease returned error: %v", err)
+	}
+	return release, nil
+}
+
+// releaseByTag asks the github api for the release of the given repo with
+// the given tag
+func releaseByTag(owner string, repo string, tag string) (*github.RepositoryRelease, error) {
+	client := github.NewClient(nil)
+	ctx := context.Background()
+	release, _, err := client.Repositories.GetReleaseByTag(ctx, owner, repo, tag)
+	if err != nil {
+		return nil, fmt.Errorf("Repositories.GetReleaseByTag returned error: %v", err)
+	}
+	return release, nil
+}
+
+// latestMatchingRelease asks the github api for the newest release of the
+// given repo whose tag matches the given regexp. Like the latest release,
+// drafts and prereleases aren't considered.
+func latestMatchingRelease(owner string, repo string, tagRegexp *regexp.Regexp) (*github.RepositoryRelease, error) {
+	client := github.NewClient(nil)
+	ctx := context.Background()
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		releases, resp, err := client.Repositories.ListReleases(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("Repositories.ListReleases returned error: %v", err)
+		}
+		for _, release := range releases {
+			if release.GetDraft() || release.GetPrerelease() {
+				continue
+			}
+			if tagRegexp.MatchString(release.GetTagName()) {
+				return release, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return nil, fmt.Errorf("no release of %s/%s has a tag matching \"%s\"", owner, repo, tagRegexp)
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// filterAssets returns the assets of the given release whose names match
+// every one of the given filters
+func filterAssets(release *github.RepositoryRelease, filters []*regexp.Regexp) []*github.ReleaseAsset {
+	var result []*github.ReleaseAsset
 	for _, asset := range release.Assets {
 		assetName := asset.GetName()
 		for _, filter := range filters {
@@ -35,10 +99,9 @@ func latestReleasedAssets(owner string, repo string, filters []*regexp.Regexp, s
 				goto CONTINUE_OUTER
 			}
 		}
-		result = append(result, asset.GetBrowserDownloadURL())
+		result = append(result, asset)
 	CONTINUE_OUTER:
 	}
-
 	return result
 }
 
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/backplane/ghlatest/extract"
+	"github.com/backplane/ghlatest/util"
+	"github.com/google/go-github/v33/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// installOptions controls how a release asset is selected and installed
+type installOptions struct {
+	binDir    string          // directory the executables are installed to
+	filters   []string        // regexes the asset name must match, besides the current OS and architecture
+	pin       string          // regex the release tag must match, otherwise the latest release is installed
+	keep      []string        // keep rules selecting the files to consider
+	name      string          // name for the installed executable, if there's only one
+	overwrite bool            // replace existing files in binDir
+	owned     map[string]bool // existing files which an earlier install placed and which may be replaced
+	dataDir   string          // directory holding the state file and the store, the user's data directory if empty
+	digest    string          // sha256 digest the release asset must have, if it's known in advance
+	hold      bool            // the release was chosen with use, upgrades leave it alone
+}
+
+// stateMu serializes the updates of the state file by parallel installs
+var stateMu sync.Mutex
+
+// defaultBinDir returns the directory executables are installed to when
+// neither --bin-dir nor $GHLATEST_BIN is given
+func defaultBinDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine the home directory, use --bin-dir; error: %s", err)
+	}
+	return filepath.Join(home, ".local", "bin"), nil
+}
+
+// resolveRelease finds the release of the given repo to install, the latest
+// one or (with a pin) the newest one whose tag matches the pin
+func resolveRelease(owner string, repo string, pin string) (*github.RepositoryRelease, error) {
+	if pin == "" {
+		return latestRelease(owner, repo)
+	}
+	tagRegexp, err := regexp.Compile(pin)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pin \"%s\"; error: %s", pin, err)
+	}
+	return latestMatchingRelease(owner, repo, tagRegexp)
+}
+
+// resolveAsset selects the asset of the given release which is to be
+// installed, which must be the only one for the current OS and architecture
+// that matches the filters
+func resolveAsset(release *github.RepositoryRelease, opts installOptions) (*github.ReleaseAsset, error) {
+	return resolveAssetFor(release, opts, currentPlatform())
+}
+
+// resolveAssetFor selects the asset of the given release for the given
+// platform, which must be the only one for it that matches the filters
+func resolveAssetFor(release *github.RepositoryRelease, opts installOptions, p platform) (*github.ReleaseAsset, error) {
+	filters := p.regexps()
+	for _, filterString := range opts.filters {
+		filter, err := regexp.Compile(filterString)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter \"%s\"; error: %s", filterString, err)
+		}
+		filters = append(filters, filter)
+	}
+	assets := filterAssets(release, filters)
+	if len(assets) != 1 {
+		return nil, fmt.Errorf("found %d matching downloads for %s in %s, use a -f flag to get the match count down to exactly 1", len(assets), p, release.GetTagName())
+	}
+	return assets[0], nil
+}
+
+// installRelease installs the given asset of the given release into the
+// versioned store, links the bin directory to it and records the install,
+// along with the options used, in the state file. The version which was
+// active before remains in the store for rollbacks. The state is only loaded
+// once the asset is in the store, so installs of different repos may run in
+// parallel.
+func installRelease(owner string, repo string, release *github.RepositoryRelease, asset *github.ReleaseAsset, opts installOptions) (*installRecord, error) {
+	var err error
+	dir := opts.dataDir
+	if dir == "" {
+		if dir, err = dataDir(); err != nil {
+			return nil, err
+		}
+	}
+	binDir, err := filepath.Abs(opts.binDir)
+	if err != nil {
+		return nil, err
+	}
+
+	vdir, err := versionDir(dir, owner+"/"+repo, release.GetTagName())
+	if err != nil {
+		return nil, err
+	}
+	v, err := installAsset(asset.GetBrowserDownloadURL(), repo, vdir, opts)
+	if err != nil {
+		return nil, err
+	}
+	v.Tag = release.GetTagName()
+	v.AssetID = asset.GetID()
+	v.InstalledAt = time.Now().UTC()
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	state, err := loadStateAt(dir)
+	if err != nil {
+		return nil, err
+	}
+	rec := &installRecord{Repo: owner + "/" + repo}
+	if prev := state.find(rec.Repo); prev != nil {
+		rec = prev
+	}
+	rec.BinDir = binDir
+	rec.Pin = opts.pin
+	rec.Filters = opts.filters
+	rec.Keep = opts.keep
+	rec.Name = opts.name
+	rec.Held = opts.hold
+	rec.addVersion(*v)
+
+	created, err := missingDirs(rec.BinDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(rec.BinDir, 0755); err != nil {
+		return nil, fmt.Errorf("creating the bin directory \"%s\" failed; error: %s", rec.BinDir, err)
+	}
+	rec.Dirs = append(rec.Dirs, created...)
+
+	activateErr := activate(rec, v.Tag, opts)
+	state.record(*rec)
+	if err := state.save(); err != nil {
+		return rec, err
+	}
+	if activateErr != nil {
+		return rec, activateErr
+	}
+	log.Infof("recorded the install of %s %s in %s", rec.Repo, rec.Tag, state.path)
+	return rec, nil
+}
+
+// installAsset downloads the release asset at the given URL, extracts it and
+// moves the executables it contains into the given version directory of the
+// store, replacing any earlier install of the version. The returned
+// installedVersion describes the asset and the installed files. The work is
+// staged in a temporary directory alongside the version directory, so the
+// version appears in the store complete, with a single rename.
+func installAsset(assetURL string, repo string, dir string, opts installOptions) (*installedVersion, error) {
+	assetName := assetURL[strings.LastIndex(assetURL, "/")+1:]
+	if !filenameRegexp.MatchString(assetName) {
+		return nil, fmt.Errorf("could not correctly calculate a filename from %s", assetURL)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return nil, fmt.Errorf("creating the store directory \"%s\" failed; error: %s", filepath.Dir(dir), err)
+	}
+	stage, err := os.MkdirTemp(filepath.Dir(dir), ".ghlatest-install-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(stage)
+
+	archivePath := filepath.Join(stage, assetName)
+	if err := util.DownloadFile(assetURL, archivePath, 0644, false); err != nil {
+		return nil, err
+	}
+	v := &installedVersion{Asset: assetName}
+	if v.Digest, err = util.FileDigest(archivePath); err != nil {
+		return nil, err
+	}
+	if opts.digest != "" && v.Digest != opts.digest {
+		return nil, fmt.Errorf("the digest of \"%s\" is %s, but %s was expected", assetName, v.Digest, opts.digest)
+	}
+	res, err := extract.ExtractFile(archivePath, opts.keep, extract.Options{
+		Bin:       true,
+		Name:      assetName,
+		OutputDir: filepath.Join(stage, "files"),
+	})
+	if res != nil {
+		res.LogSummary()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract \"%s\"; error: %s", assetName, err)
+	}
+	executables := res.Executables()
+	if len(executables) == 0 {
+		return nil, fmt.Errorf("no executables were found in \"%s\"; use --keep to select them", assetName)
+	}
+	if opts.name != "" && len(executables) != 1 {
+		return nil, fmt.Errorf("found %d executables in \"%s\", --name can only be used when there's one", len(executables), assetName)
+	}
+
+	// gather the executables in the staged version directory
+	staged := filepath.Join(stage, "version")
+	if err := os.Mkdir(staged, 0755); err != nil {
+		return nil, err
+	}
+	v.Files = make([]installedFile, len(executables))
+	sources := make(map[string]string) // install name -> executable
+	for i, src := range executables {
+		name := installName(src, repo, opts.name, len(executables))
+		if other, found := sources[name]; found {
+			return nil, fmt.Errorf("\"%s\" has more than one executable named \"%s\" (%s and %s); use --keep to select one", assetName, name, stagedName(stage, other), stagedName(stage, src))
+		}
+		sources[name] = src
+		if err := os.Rename(src, filepath.Join(staged, name)); err != nil {
+			return nil, err
+		}
+		digest, err := util.FileDigest(filepath.Join(staged, name))
+		if err != nil {
+			return nil, err
+		}
+		v.Files[i] = installedFile{Path: filepath.Join(dir, name), Digest: digest}
+	}
+
+	// an earlier install of the version is swapped out before it's removed
+	if _, err := os.Lstat(dir); err == nil {
+		if err := os.Rename(dir, filepath.Join(stage, "replaced")); err != nil {
+			return nil, fmt.Errorf("replacing \"%s\" failed; error: %s", dir, err)
+		}
+	}
+	if err := os.Rename(staged, dir); err != nil {
+		return nil, fmt.Errorf("installing \"%s\" failed; error: %s", dir, err)
+	}
+	log.Infof("installed %s into %s", assetName, dir)
+	return v, nil
+}
+
+// missingDirs returns the absolute paths of the given directory and of its
+// parents which don't exist yet, deepest first
+func missingDirs(dir string) ([]string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	missing := make([]string, 0)
+	for {
+		if _, err := os.Lstat(dir); err == nil {
+			return missing, nil
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+		missing = append(missing, dir)
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return missing, nil
+		}
+		dir = parent
+	}
+}
+
+// uninstall removes the links and the versions placed by the given install,
+// along with the directories it created if they're left empty. Nothing is
+// removed if any of the files was changed since it was installed (or a link
+// was replaced), unless force is set. Files which no longer exist are
+// skipped.
+func uninstall(rec *installRecord, force bool) error {
+	remove := make([]string, 0)
+	for _, link := range rec.Links {
+		if _, err := os.Lstat(link); os.IsNotExist(err) {
+			log.Warnf("%s was installed by %s but it no longer exists", link, rec.Repo)
+			continue
+		}
+		if !isStoreLink(rec, link) && !force {
+			return fmt.Errorf("\"%s\" is no longer a link to the installed version; use --force to remove it anyway", link)
+		}
+		remove = append(remove, link)
+	}
+	for _, v := range rec.Versions {
+		for _, f := range v.Files {
+			digest, err := util.FileDigest(f.Path)
+			if os.IsNotExist(err) {
+				log.Warnf("%s was installed by %s %s but it no longer exists", f.Path, rec.Repo, v.Tag)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if digest != f.Digest && !force {
+				return fmt.Errorf("\"%s\" was changed since it was installed; use --force to remove it anyway", f.Path)
+			}
+			remove = append(remove, f.Path)
+		}
+	}
+
+	for _, path := range remove {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("removing \"%s\" failed; error: %s", path, err)
+		}
+		log.Infof("removed %s", path)
+	}
+
+	// the version directories, the store directories of the repo and the
+	// directories the install created
+	dirs := make([]string, 0)
+	for _, v := range rec.Versions {
+		if len(v.Files) > 0 {
+			dirs = append(dirs, filepath.Dir(v.Files[0].Path))
+		}
+	}
+	if len(dirs) > 0 {
+		dirs = append(dirs, filepath.Dir(dirs[0]), filepath.Dir(filepath.Dir(dirs[0])))
+	}
+	for _, dir := range append(dirs, rec.Dirs...) {
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) > 0 {
+			// it's gone or something else uses it now
+			continue
+		}
+		if err := os.Remove(dir); err != nil {
+			return fmt.Errorf("removing the directory \"%s\" failed; error: %s", dir, err)
+		}
+		log.Infof("removed the empty directory %s", dir)
+	}
+	return nil
+}
+
+// stagedName returns the path of the given extracted file within the asset
+func stagedName(stage string, path string) string {
+	if rel, err := filepath.Rel(filepath.Join(stage, "files"), path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// checkInstallName returns an error if the given name, given by --name or in
+// the manifest, isn't a plain file name which stays in the directory it's
+// installed to
+func checkInstallName(name string) error {
+	if name == "" {
+		return nil
+	}
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("the name \"%s\" must be a file name without a directory", name)
+	}
+	return nil
+}
+
+// installName chooses the name an extracted executable is installed under.
+// A lone executable which is named for a platform (e.g. "jq-linux-amd64") is
+// installed under the repo's name, others keep their own names.
+func installName(path string, repo string, name string, count int) string {
+	if name != "" {
+		return name
+	}
+	base := filepath.Base(path)
+	if count != 1 || !(archRegexp.MatchString(base) || osRegexp.MatchString(base)) {
+		return base
+	}
+	if runtime.GOOS == "windows" && strings.EqualFold(filepath.Ext(base), ".exe") {
+		return repo + ".exe"
+	}
+	return repo
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"debug/elf"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+var (
+	hostLibcOnce sync.Once
+	hostLibcName string
+)
+
+// hostLibc returns the C library of the host, as detectLibc does. It's only
+// detected the first time it's needed, as that reads files from the host.
+func hostLibc() string {
+	hostLibcOnce.Do(func() {
+		hostLibcName = detectLibc()
+	})
+	return hostLibcName
+}
+
+// detectLibc returns the C library of the host, "glibc" or "musl", or "" if
+// it couldn't be determined or the host isn't running linux. The dynamic
+// loader which /bin/sh requests is checked first, then the loaders in /lib.
+func detectLibc() string {
+	if runtime.GOOS != "linux" {
+		return ""
+	}
+	if f, err := elf.Open("/bin/sh"); err == nil {
+		defer f.Close()
+		for _, prog := range f.Progs {
+			if prog.Type != elf.PT_INTERP {
+				continue
+			}
+			buf := make([]byte, prog.Filesz)
+			if _, err := prog.ReadAt(buf, 0); err != nil {
+				break
+			}
+			if libc := loaderLibc(strings.TrimRight(string(buf), "\x00")); libc != "" {
+				return libc
+			}
+		}
+	}
+	for _, pattern := range []string{"/lib/ld-linux*.so*", "/lib64/ld-linux*.so*", "/lib/*/ld-linux*.so*", "/lib/ld-musl-*.so*"} {
+		matches, _ := filepath.Glob(pattern)
+		for _, loader := range matches {
+			if libc := loaderLibc(loader); libc != "" {
+				return libc
+			}
+		}
+	}
+	return ""
+}
+
+// loaderLibc returns the C library which the dynamic loader at the given path
+// belongs to, or "" if it isn't a known one
+func loaderLibc(loader string) string {
+	name := filepath.Base(loader)
+	switch {
+	case strings.HasPrefix(name, "ld-musl-"):
+		return "musl"
+	case strings.HasPrefix(name, "ld-linux"):
+		return "glibc"
+	}
+	return ""
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/backplane/ghlatest/util"
+	"github.com/google/go-github/v33/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// lockFileName is the name of the file, next to the manifest, which records
+// the exact release assets the manifest's tools resolved to
+const lockFileName = "ghlatest.lock"
+
+// lockFile pins each tool of a manifest to a release and, for each of the
+// manifest's platforms, to a release asset with a known digest
+type lockFile struct {
+	Platforms []string     `json:"platforms"`
+	Tools     []lockedTool `json:"tools"`
+
+	path string
+}
+
+// lockedTool is the release a manifest tool resolved to
+type lockedTool struct {
+	Repo   string                 `json:"repo"`   // owner/repo
+	Spec   manifestTool           `json:"spec"`   // the manifest entry the tool was locked from
+	Tag    string                 `json:"tag"`    // tag of the release
+	Assets map[string]lockedAsset `json:"assets"` // the release assets, by platform (os/arch)
+}
+
+// lockedAsset is a release asset a manifest tool resolved to
+type lockedAsset struct {
+	ID     int64  `json:"id"`     // github's ID for the release asset
+	Name   string `json:"name"`   // name of the release asset
+	URL    string `json:"url"`    // download URL of the release asset
+	Digest string `json:"digest"` // sha256 digest of the release asset
+}
+
+// lockTools resolves each of the manifest's tools to a release, and to the
+// release asset for each of the manifest's platforms, running up to the given
+// number of tools at once. The assets are downloaded to record their digests,
+// unless dryRun is set.
+func lockTools(m *manifest, jobs int, dryRun bool) (*lockFile, error) {
+	lock := &lockFile{path: m.lockPath(), Tools: make([]lockedTool, len(m.Tools))}
+	platforms := m.platforms()
+	for _, p := range platforms {
+		lock.Platforms = append(lock.Platforms, p.String())
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	errs := make([]error, len(m.Tools))
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, tool := range m.Tools {
+		wg.Add(1)
+		go func(i int, tool manifestTool) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if errs[i] = lockTool(&lock.Tools[i], tool, platforms, dryRun); errs[i] != nil {
+				log.Errorf("locking %s failed; error: %s", tool.Repo, errs[i])
+			}
+		}(i, tool)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return lock, fmt.Errorf("%d of the tools in %s could not be locked", failed, m.path)
+	}
+	return lock, nil
+}
+
+// lockTool resolves the given tool for the given platforms into locked
+func lockTool(locked *lockedTool, tool manifestTool, platforms []platform, dryRun bool) error {
+	owner, repo, _ := repoURLInfo(tool.Repo)
+	locked.Repo = owner + "/" + repo
+	locked.Spec = tool
+	locked.Assets = make(map[string]lockedAsset)
+
+	release, err := tool.release(owner, repo)
+	if err != nil {
+		return err
+	}
+	locked.Tag = release.GetTagName()
+
+	opts := tool.options("", "")
+	for _, p := range platforms {
+		asset, err := resolveAssetFor(release, opts, p)
+		if err != nil {
+			return err
+		}
+		a := lockedAsset{ID: asset.GetID(), Name: asset.GetName(), URL: asset.GetBrowserDownloadURL()}
+		if !dryRun {
+			log.Infof("computing the digest of %s", a.URL)
+			if a.Digest, err = util.URLDigest(a.URL); err != nil {
+				return fmt.Errorf("downloading \"%s\" failed; error: %s", a.URL, err)
+			}
+		}
+		locked.Assets[p.String()] = a
+	}
+	return nil
+}
+
+// loadLock reads the lock file at the given path
+func loadLock(path string) (*lockFile, error) {
+	doc, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading the lock file failed, use the lock command to create it; error: %s", err)
+	}
+	lock := &lockFile{path: path}
+	if err := json.Unmarshal(doc, lock); err != nil {
+		return nil, fmt.Errorf("reading the lock file \"%s\" failed; error: %s", path, err)
+	}
+	return lock, nil
+}
+
+// save writes the lock file
+func (l *lockFile) save() error {
+	doc, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(l.path, append(doc, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing the lock file \"%s\" failed; error: %s", l.path, err)
+	}
+	return nil
+}
+
+// find returns the locked tool of the given repo (owner/repo), or nil if it
+// isn't in the lock file
+func (l *lockFile) find(repo string) *lockedTool {
+	for i := range l.Tools {
+		if l.Tools[i].Repo == repo {
+			return &l.Tools[i]
+		}
+	}
+	return nil
+}
+
+// check returns an error if the lock file is stale: if it wasn't locked for
+// the platforms the manifest lists, if it doesn't have an entry for each of
+// the manifest's tools which was locked from the tool's current manifest
+// entry, or if it has entries for tools which are no longer in the manifest.
+// A manifest which lists no platforms is locked for the platform of whoever
+// ran the lock command, then it's an error, though not a stale lock, if that
+// isn't the current platform.
+func (l *lockFile) check(m *manifest) error {
+	stale := func(format string, a ...interface{}) error {
+		return fmt.Errorf("%s is stale, run the lock command to update it: %s", l.path, fmt.Sprintf(format, a...))
+	}
+	if len(m.Platforms) > 0 {
+		// the order in which the platforms are listed doesn't matter
+		want := make([]string, 0, len(m.Platforms))
+		for _, p := range m.platforms() {
+			want = append(want, p.String())
+		}
+		got := append([]string{}, l.Platforms...)
+		sort.Strings(want)
+		sort.Strings(got)
+		if strings.Join(want, " ") != strings.Join(got, " ") {
+			return stale("it's locked for %s but the manifest lists %s", strings.Join(got, ", "), strings.Join(want, ", "))
+		}
+	}
+	if len(l.Tools) != len(m.Tools) {
+		return stale("it has %d tools but the manifest lists %d", len(l.Tools), len(m.Tools))
+	}
+	for _, tool := range m.Tools {
+		owner, repo, _ := repoURLInfo(tool.Repo)
+		locked := l.find(owner + "/" + repo)
+		if locked == nil {
+			return stale("%s/%s isn't locked", owner, repo)
+		}
+		want, _ := json.Marshal(tool)
+		got, _ := json.Marshal(locked.Spec)
+		if string(want) != string(got) {
+			return stale("the manifest entry of %s was changed", locked.Repo)
+		}
+	}
+	if current := currentPlatform().String(); len(m.Platforms) == 0 && !slices.Contains(l.Platforms, current) {
+		return fmt.Errorf("%s isn't locked for this platform (%s), it's locked for %s; list the platforms to lock in the manifest's platforms", l.path, current, strings.Join(l.Platforms, ", "))
+	}
+	return nil
+}
+
+// target returns the release and the release asset the tool is locked to for
+// the given platform, along with the asset's digest
+func (t *lockedTool) target(p platform) (*github.RepositoryRelease, *github.ReleaseAsset, string, error) {
+	a, ok := t.Assets[p.String()]
+	if !ok {
+		return nil, nil, "", fmt.Errorf("%s isn't locked for %s", t.Repo, p)
+	}
+	if a.Digest == "" {
+		return nil, nil, "", fmt.Errorf("the lock of %s has no digest for %s", t.Repo, p)
+	}
+	release := &github.RepositoryRelease{TagName: github.String(t.Tag)}
+	asset := &github.ReleaseAsset{ID: github.Int64(a.ID), Name: github.String(a.Name), BrowserDownloadURL: github.String(a.URL)}
+	return release, asset, a.Digest, nil
+}
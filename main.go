@@ -31,12 +31,14 @@ var (
 	osRegexp       *regexp.Regexp
 )
 
-func init() {
-	var arch_subregex, os_subregex string
+// archRegexpFor returns the regexp which matches the release asset names for
+// the given architecture (in GOARCH terms)
+func archRegexpFor(goarch string) *regexp.Regexp {
+	var arch_subregex string
 
 	// to see the available GOARCH and GOOS options, run "go tool dist list"
 
-	switch runtime.GOARCH {
+	switch goarch {
 	case `amd64`:
 		arch_subregex = `(amd64|x64|x86_64)`
 	case `arm64`:
@@ -46,21 +48,50 @@ func init() {
 	case `arm`:
 		arch_subregex = `arm(v?[\d]l?|hf|el|aarch32)?`
 	default:
-		arch_subregex = runtime.GOARCH
+		arch_subregex = regexp.QuoteMeta(goarch)
 	}
-	archRegexp = regexp.MustCompile(`(?i)(^|[^0-9a-fA-F])` + arch_subregex + `([^0-9a-fA-F]|$)`)
+	return regexp.MustCompile(`(?i)(^|[^0-9a-fA-F])` + arch_subregex + `([^0-9a-fA-F]|$)`)
+}
+
+// osRegexpFor returns the regexp which matches the release asset names for
+// the given operating system (in GOOS terms)
+func osRegexpFor(goos string) *regexp.Regexp {
+	var os_subregex string
 
-	switch runtime.GOOS {
+	switch goos {
 	case `darwin`:
 		os_subregex = `(darwin|macos|osx)`
 	case `freebsd`:
 		os_subregex = `(freebsd|fbsd)`
 	case `windows`:
-		os_subregex = `(windows|win)`
+		// "win" is also the end of "darwin" and the start of many words, so it
+		// can't follow or be followed by a letter
+		return regexp.MustCompile(`(?i)(^|[^a-z0-9])(windows|win(32|64)?)([^a-z0-9]|$)`)
 	default:
-		os_subregex = runtime.GOOS
+		os_subregex = regexp.QuoteMeta(goos)
 	}
-	osRegexp = regexp.MustCompile(`(?i)[^0-9a-fA-F]` + os_subregex + `[^0-9a-fA-F]`)
+	return regexp.MustCompile(`(?i)(^|[^0-9a-fA-F])` + os_subregex + `[^0-9a-fA-F]`)
+}
+
+// libcRegexpFor returns the regexp which matches the release asset names for
+// the given C library ("glibc" or "musl")
+func libcRegexpFor(libc string) *regexp.Regexp {
+	var libc_subregex string
+
+	switch libc {
+	case `glibc`:
+		libc_subregex = `(gnu|glibc)(eabi(hf)?)?`
+	case `musl`:
+		libc_subregex = `musl(eabi(hf)?)?`
+	default:
+		libc_subregex = regexp.QuoteMeta(libc)
+	}
+	return regexp.MustCompile(`(?i)(^|[^0-9a-fA-F])` + libc_subregex + `([^0-9a-fA-F]|$)`)
+}
+
+func init() {
+	archRegexp = archRegexpFor(runtime.GOARCH)
+	osRegexp = osRegexpFor(runtime.GOOS)
 
 	cli.VersionPrinter = func(c *cli.Context) {
 		fmt.Printf("version %s, commit %s, built at %s by %s\n", version, commit, date, builtBy)
@@ -93,6 +124,10 @@ func main() {
 					return err
 				},
 			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Report what would be downloaded, extracted, overwritten or removed without changing any files",
+			},
 		},
 		Commands: []*cli.Command{
 			{
@@ -118,6 +153,26 @@ func main() {
 						Name:  "current-os",
 						Usage: "Filter release assets with a regex describing the current operating system",
 					},
+					&cli.BoolFlag{
+						Name:  "current-libc",
+						Usage: "Filter release assets with a regex describing the C library of the current system (glibc or musl, detected on linux)",
+					},
+					&cli.StringFlag{
+						Name:  "os",
+						Usage: "Filter release assets with a regex describing the given operating system (in GOOS terms, e.g. \"linux\" or \"darwin\") instead of the current one",
+					},
+					&cli.StringFlag{
+						Name:  "arch",
+						Usage: "Filter release assets with a regex describing the given processor architecture (in GOARCH terms, e.g. \"amd64\" or \"arm64\") instead of the current one",
+					},
+					&cli.StringSliceFlag{
+						Name:  "platforms",
+						Usage: "Resolve one release asset for each of the given platforms (as os/arch, e.g. 'linux/amd64,linux/arm64,darwin/arm64'); downloads go into os/arch directories",
+					},
+					&cli.BoolFlag{
+						Name:  "auto",
+						Usage: "Pick the release asset which best suits the current (or given) OS and architecture, preferring archives and skipping checksums, signatures, SBOMs, packages and debug symbols; --verbosity debug explains the ranking",
+					},
 					&cli.BoolFlag{
 						Name:    "source",
 						Aliases: []string{"s"},
@@ -149,6 +204,26 @@ func main() {
 						Name:  "current-os",
 						Usage: "Filter release assets with a regex describing the current operating system",
 					},
+					&cli.BoolFlag{
+						Name:  "current-libc",
+						Usage: "Filter release assets with a regex describing the C library of the current system (glibc or musl, detected on linux)",
+					},
+					&cli.StringFlag{
+						Name:  "os",
+						Usage: "Filter release assets with a regex describing the given operating system (in GOOS terms, e.g. \"linux\" or \"darwin\") instead of the current one",
+					},
+					&cli.StringFlag{
+						Name:  "arch",
+						Usage: "Filter release assets with a regex describing the given processor architecture (in GOARCH terms, e.g. \"amd64\" or \"arm64\") instead of the current one",
+					},
+					&cli.StringSliceFlag{
+						Name:  "platforms",
+						Usage: "Resolve one release asset for each of the given platforms (as os/arch, e.g. 'linux/amd64,linux/arm64,darwin/arm64'); downloads go into os/arch directories",
+					},
+					&cli.BoolFlag{
+						Name:  "auto",
+						Usage: "Pick the release asset which best suits the current (or given) OS and architecture, preferring archives and skipping checksums, signatures, SBOMs, packages and debug symbols; --verbosity debug explains the ranking",
+					},
 					&cli.BoolFlag{
 						Name:    "source",
 						Aliases: []string{"s"},
@@ -157,7 +232,7 @@ func main() {
 					&cli.StringFlag{
 						Name:    "outputpath",
 						Aliases: []string{"o"},
-						Usage:   "The name of the file to write to",
+						Usage:   "The name of the file to write to, \"-\" writes the download to stdout",
 					},
 					&cli.StringFlag{
 						Name:    "mode",
@@ -168,25 +243,202 @@ func main() {
 					&cli.BoolFlag{
 						Name:    "extract",
 						Aliases: []string{"x"},
-						Usage:   "Extract files from the downloaded archive (supports zip, gzip, bzip2, xz, 7z, and tar formats)",
+						Usage:   "Extract files from the downloaded archive (supports zip, gzip, bzip2, xz, zstd, lz4, 7z, tar, cpio, ar, deb, and rpm formats)",
 					},
 					&cli.StringSliceFlag{
 						Name:    "keep",
 						Aliases: []string{"k"},
-						Usage:   "When extracting, only keep the files matching this/these regex(s)",
+						Usage:   "When extracting, only keep the files matching this/these regex(s); use 'regex=>replacement' to rename matching files, or the downloaded file when not extracting (capture groups like $1 are supported)",
 					},
 					&cli.BoolFlag{
 						Name:  "overwrite",
 						Usage: "When extracting, if one of the output files already exists, overwrite it",
 					},
+					&cli.BoolFlag{
+						Name:  "same-owner",
+						Usage: "When extracting as root, restore the file ownership recorded in the archive",
+					},
+					&cli.BoolFlag{
+						Name:  "xattrs",
+						Usage: "When extracting tar archives, restore extended attributes (including file capabilities)",
+					},
+					&cli.StringFlag{
+						Name:  "on-error",
+						Value: "stop",
+						Usage: "What to do when an archive entry fails to extract: \"stop\" or \"skip\" to the next entry",
+					},
+					&cli.BoolFlag{
+						Name:    "nested",
+						Aliases: []string{"recursive"},
+						Usage:   "When extracting, also extract the archives found inside the archive which --keep selects, with all of their contents (in place of the archives themselves)",
+					},
+					&cli.IntFlag{
+						Name:  "nested-depth",
+						Value: 3,
+						Usage: "When extracting nested archives, the maximum number of levels to descend",
+					},
+					&cli.BoolFlag{
+						Name:  "bin",
+						Usage: "When extracting, detect the executables (ELF, Mach-O, PE or scripts with a shebang), set the executable bits only on those and print their paths; with --keep it's an error if none is found",
+					},
+					&cli.BoolFlag{
+						Name:  "list-contents",
+						Usage: "Instead of extracting, list the contents of the downloaded archive and which files --keep would select",
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "When listing archive contents, print them as json",
+					},
 					&cli.BoolFlag{
 						Name:    "remove-archive",
 						Aliases: []string{"rm"},
-						Usage:   "After extracting the archive, delete it",
+						Usage:   "After listing the archive's contents, delete it (with --extract the archive is streamed and never written)",
 					},
 				},
 				Action: downloadHandler,
 			},
+			{
+				Name:      "install",
+				Aliases:   []string{"i"},
+				Usage:     "install the executables from the latest release for the current OS and architecture",
+				ArgsUsage: "owner/repo",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:    "filter",
+						Aliases: []string{"f"},
+						Usage:   "Filter release assets with the given regular expression",
+					},
+					&cli.StringSliceFlag{
+						Name:    "ifilter",
+						Aliases: []string{"i"},
+						Usage:   "Filter release assets with the given CASE-INSENSITIVE regular expression",
+					},
+					&cli.StringSliceFlag{
+						Name:    "keep",
+						Aliases: []string{"k"},
+						Usage:   "Only consider the files in the release asset matching this/these regex(s); use 'regex=>replacement' to rename matching files (capture groups like $1 are supported)",
+					},
+					&cli.StringFlag{
+						Name:    "bin-dir",
+						EnvVars: []string{"GHLATEST_BIN"},
+						Usage:   "The directory to install the executables to (default: ~/.local/bin)",
+					},
+					&cli.StringFlag{
+						Name:  "pin",
+						Usage: "Install the newest release whose tag matches this regex (e.g. '^v1\\.') instead of the latest release, upgrades keep to the pin",
+					},
+					&cli.StringFlag{
+						Name:  "name",
+						Usage: "The name to install the executable as, when the release asset contains only one",
+					},
+					&cli.BoolFlag{
+						Name:  "overwrite",
+						Usage: "If an executable already exists in the bin directory, replace it",
+					},
+				},
+				Action: installHandler,
+			},
+			{
+				Name:  "installed",
+				Usage: "list the tools installed by the install command",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "Print the installs as json, including the digests of the installed files",
+					},
+				},
+				Action: installedHandler,
+			},
+			{
+				Name:      "outdated",
+				Usage:     "list the installed tools which have a newer release, exiting non-zero if there are any",
+				ArgsUsage: "[owner/repo...]",
+				Action:    outdatedHandler,
+			},
+			{
+				Name:      "upgrade",
+				Usage:     "install the newer releases of the given installed tools (or of all of them, except those held at a version by use or rollback)",
+				ArgsUsage: "[owner/repo...]",
+				Action:    upgradeHandler,
+			},
+			{
+				Name:      "uninstall",
+				Usage:     "remove the files placed by the install of the given tool",
+				ArgsUsage: "owner/repo",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Remove the installed files even if they were changed since they were installed",
+					},
+				},
+				Action: uninstallHandler,
+			},
+			{
+				Name:      "use",
+				Usage:     "switch the bin directory links of an installed tool to the given version, installing it if it isn't in the store",
+				ArgsUsage: "owner/repo@tag",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "overwrite",
+						Usage: "If an executable already exists in the bin directory, replace it",
+					},
+				},
+				Action: useHandler,
+			},
+			{
+				Name:      "rollback",
+				Usage:     "switch the bin directory links of an installed tool back to the version which was active before",
+				ArgsUsage: "owner/repo",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "overwrite",
+						Usage: "If an executable already exists in the bin directory, replace it",
+					},
+				},
+				Action: rollbackHandler,
+			},
+			{
+				Name:  "sync",
+				Usage: "install the tools listed in the project's " + manifestFileName + " into its bin directory (.bin by default), upgrading them as needed",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "file",
+						Aliases: []string{"f"},
+						Value:   manifestFileName,
+						Usage:   "The manifest listing the tools",
+					},
+					&cli.IntFlag{
+						Name:    "jobs",
+						Aliases: []string{"j"},
+						Value:   4,
+						Usage:   "The number of tools to install at once",
+					},
+					&cli.BoolFlag{
+						Name:  "frozen",
+						Usage: "Install exactly the release assets recorded in " + lockFileName + ", verifying their digests; fail if the lock file is stale",
+					},
+				},
+				Action: syncHandler,
+			},
+			{
+				Name:  "lock",
+				Usage: "resolve the tools listed in the project's " + manifestFileName + " to release assets for each of its platforms and record them, with their digests, in " + lockFileName,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "file",
+						Aliases: []string{"f"},
+						Value:   manifestFileName,
+						Usage:   "The manifest listing the tools",
+					},
+					&cli.IntFlag{
+						Name:    "jobs",
+						Aliases: []string{"j"},
+						Value:   4,
+						Usage:   "The number of tools to resolve at once",
+					},
+				},
+				Action: lockHandler,
+			},
 			{
 				Name:    "json",
 				Aliases: []string{"j"},
@@ -197,7 +449,7 @@ func main() {
 			{
 				Name:    "extract",
 				Aliases: []string{"x"},
-				Usage:   "Extract files from the given archive (supports zip, gzip, bzip2, xz, 7z, and tar formats)",
+				Usage:   "Extract files from the given archive (supports zip, gzip, bzip2, xz, zstd, lz4, 7z, tar, cpio, ar, deb, and rpm formats)",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:    "outputpath",
@@ -210,15 +462,55 @@ func main() {
 						Value:   "0755",
 						Usage:   "Set the output file's protection mode (ala chmod)",
 					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "The archive's format given as a filename extension (e.g. \"tar.gz\" or \"zip\"), otherwise it's detected; useful when reading the archive from stdin (\"-\")",
+					},
 					&cli.StringSliceFlag{
 						Name:    "keep",
 						Aliases: []string{"k"},
-						Usage:   "When extracting, only keep the files matching this/these regex(s)",
+						Usage:   "When extracting, only keep the files matching this/these regex(s); use 'regex=>replacement' to rename matching files (capture groups like $1 are supported)",
 					},
 					&cli.BoolFlag{
 						Name:  "overwrite",
 						Usage: "When extracting, if one of the output files already exists, overwrite it",
 					},
+					&cli.BoolFlag{
+						Name:  "same-owner",
+						Usage: "When extracting as root, restore the file ownership recorded in the archive",
+					},
+					&cli.BoolFlag{
+						Name:  "xattrs",
+						Usage: "When extracting tar archives, restore extended attributes (including file capabilities)",
+					},
+					&cli.StringFlag{
+						Name:  "on-error",
+						Value: "stop",
+						Usage: "What to do when an archive entry fails to extract: \"stop\" or \"skip\" to the next entry",
+					},
+					&cli.BoolFlag{
+						Name:    "nested",
+						Aliases: []string{"recursive"},
+						Usage:   "When extracting, also extract the archives found inside the archive which --keep selects, with all of their contents (in place of the archives themselves)",
+					},
+					&cli.IntFlag{
+						Name:  "nested-depth",
+						Value: 3,
+						Usage: "When extracting nested archives, the maximum number of levels to descend",
+					},
+					&cli.BoolFlag{
+						Name:  "bin",
+						Usage: "When extracting, detect the executables (ELF, Mach-O, PE or scripts with a shebang), set the executable bits only on those and print their paths; with --keep it's an error if none is found",
+					},
+					&cli.BoolFlag{
+						Name:    "list",
+						Aliases: []string{"l"},
+						Usage:   "Instead of extracting, list the contents of the archive and which files --keep would select",
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "When listing archive contents, print them as json",
+					},
 					&cli.BoolFlag{
 						Name:    "remove-archive",
 						Aliases: []string{"rm"},
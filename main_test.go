@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestOSRegexpFor(t *testing.T) {
+	tests := []struct {
+		name string
+		os   string // the OS the name is for, "" for none
+	}{
+		{"linux-amd64.tar.gz", "linux"},
+		{"tool-linux-amd64.tar.gz", "linux"},
+		{"tool_Linux_x86_64.tar.gz", "linux"},
+		{"darwin-amd64.tar.gz", "darwin"},
+		{"tool-macos-arm64.zip", "darwin"},
+		{"tool_osx.tar.gz", "darwin"},
+		{"freebsd_amd64.zip", "freebsd"},
+		{"tool-windows-amd64.zip", "windows"},
+		{"tool-win64.zip", "windows"},
+		{"tool_win32.exe", "windows"},
+		{"tool-Win-x64.zip", "windows"},
+		{"tool-darwin-arm64.zip", "darwin"},
+		{"tool-winter-release.tar.gz", ""},
+		{"tool.tar.gz", ""},
+	}
+	for _, tc := range tests {
+		for _, goos := range []string{"linux", "darwin", "freebsd", "windows"} {
+			if matched := osRegexpFor(goos).MatchString(tc.name); matched != (goos == tc.os) {
+				t.Errorf("%s: osRegexpFor(%q) matched=%v", tc.name, goos, matched)
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-github/v33/github"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// manifestFileName is the name of the file listing a project's tools
+	manifestFileName = "ghlatest.yaml"
+
+	// defaultManifestBinDir is the directory, relative to the manifest, which
+	// the project's tools are installed to when the manifest doesn't say
+	defaultManifestBinDir = ".bin"
+)
+
+// manifest lists the tools a project uses, the sync command installs them
+// into the project's bin directory
+type manifest struct {
+	BinDir    string         `yaml:"bin_dir"`   // relative to the manifest's directory
+	Platforms []string       `yaml:"platforms"` // the platforms to lock, as os/arch
+	Tools     []manifestTool `yaml:"tools"`
+
+	path string // the manifest file
+}
+
+// manifestTool is a tool listed in the manifest, the fields correspond to the
+// flags of the install command. The lock file keeps a copy of the entry each
+// tool was locked from.
+type manifestTool struct {
+	Repo     string   `yaml:"repo" json:"repo"`                   // owner/repo or a github URL
+	Tag      string   `yaml:"tag" json:"tag,omitempty"`           // install exactly this release
+	Version  string   `yaml:"version" json:"version,omitempty"`   // install the newest release whose tag matches this regex, like --pin
+	Filters  []string `yaml:"filters" json:"filters,omitempty"`   // like --filter
+	IFilters []string `yaml:"ifilters" json:"ifilters,omitempty"` // like --ifilter
+	Keep     []string `yaml:"keep" json:"keep,omitempty"`         // like --keep
+	Name     string   `yaml:"name" json:"name,omitempty"`         // like --name
+}
+
+// loadManifest reads and checks the manifest at the given path
+func loadManifest(path string) (*manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading the manifest failed; error: %s", err)
+	}
+	defer f.Close()
+
+	m := &manifest{path: path}
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(m); err != nil {
+		return nil, fmt.Errorf("reading the manifest \"%s\" failed; error: %s", path, err)
+	}
+	if m.BinDir == "" {
+		m.BinDir = defaultManifestBinDir
+	}
+
+	for _, s := range m.Platforms {
+		if _, err := parsePlatform(s); err != nil {
+			return nil, fmt.Errorf("%s in \"%s\"", err, path)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i, tool := range m.Tools {
+		owner, repo, err := repoURLInfo(tool.Repo)
+		if err != nil {
+			return nil, fmt.Errorf("tool %d in \"%s\": %s", i+1, path, err)
+		}
+		if seen[owner+"/"+repo] {
+			return nil, fmt.Errorf("%s/%s is listed more than once in \"%s\"", owner, repo, path)
+		}
+		seen[owner+"/"+repo] = true
+		if tool.Tag != "" && tool.Version != "" {
+			return nil, fmt.Errorf("%s/%s in \"%s\" has both a tag and a version, only one can be given", owner, repo, path)
+		}
+		if err := checkInstallName(tool.Name); err != nil {
+			return nil, fmt.Errorf("%s/%s in \"%s\": %s", owner, repo, path, err)
+		}
+	}
+	return m, nil
+}
+
+// binDir returns the absolute path of the directory the tools are installed to
+func (m *manifest) binDir() (string, error) {
+	dir := m.BinDir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(filepath.Dir(m.path), dir)
+	}
+	return filepath.Abs(dir)
+}
+
+// platforms returns the platforms the tools are locked for, the current one
+// if the manifest doesn't list any
+func (m *manifest) platforms() []platform {
+	if len(m.Platforms) == 0 {
+		return []platform{currentPlatform()}
+	}
+	platforms := make([]platform, 0, len(m.Platforms))
+	for _, s := range m.Platforms {
+		p, _ := parsePlatform(s)
+		platforms = append(platforms, p)
+	}
+	return platforms
+}
+
+// lockPath returns the path of the lock file which goes with the manifest
+func (m *manifest) lockPath() string {
+	return filepath.Join(filepath.Dir(m.path), lockFileName)
+}
+
+// options returns the installOptions for the tool, installing into the given
+// bin directory with the given data directory
+func (t manifestTool) options(binDir string, dataDir string) installOptions {
+	opts := installOptions{
+		binDir:  binDir,
+		filters: append([]string{}, t.Filters...),
+		pin:     t.Version,
+		keep:    t.Keep,
+		name:    t.Name,
+		dataDir: dataDir,
+	}
+	for _, filterString := range t.IFilters {
+		opts.filters = append(opts.filters, "(?i)"+filterString)
+	}
+	return opts
+}
+
+// release finds the release of the tool to install: the one with the tool's
+// tag, the newest one matching its version, or the latest one
+func (t manifestTool) release(owner string, repo string) (*github.RepositoryRelease, error) {
+	if t.Tag != "" {
+		return releaseByTag(owner, repo, t.Tag)
+	}
+	return resolveRelease(owner, repo, t.Version)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// platform is a target operating system and architecture, in GOOS/GOARCH
+// terms
+type platform struct {
+	os   string
+	arch string
+	libc string // the C library, "glibc" or "musl", if it's known
+}
+
+// currentPlatform returns the platform ghlatest is running on, without its C
+// library, which is only detected (with hostLibc) where it's needed
+func currentPlatform() platform {
+	return platform{os: runtime.GOOS, arch: runtime.GOARCH}
+}
+
+// parsePlatform parses a platform given as "os/arch", e.g. "linux/arm64"
+func parsePlatform(s string) (platform, error) {
+	goos, goarch, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok || goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+		return platform{}, fmt.Errorf("invalid platform \"%s\", it should be given as os/arch (e.g. linux/amd64)", s)
+	}
+	return platform{os: strings.ToLower(goos), arch: strings.ToLower(goarch)}, nil
+}
+
+// String returns the platform as "os/arch"
+func (p platform) String() string {
+	return p.os + "/" + p.arch
+}
+
+// regexps returns the regexps which match the release asset names for the
+// platform's operating system and architecture
+func (p platform) regexps() []*regexp.Regexp {
+	return []*regexp.Regexp{osRegexpFor(p.os), archRegexpFor(p.arch)}
+}
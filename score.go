@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v33/github"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// the operating systems and architectures which are recognized in asset
+	// names, assets naming a platform other than the target are penalized
+	knownOSes   = []string{"linux", "darwin", "windows", "freebsd", "openbsd", "netbsd", "android", "illumos", "solaris"}
+	knownArches = []string{"amd64", "arm64", "386", "arm", "ppc64le", "s390x", "riscv64", "mips64le", "loong64"}
+
+	// assets which are never the download itself
+	checksumRegexp  = regexp.MustCompile(`(?i)(\.(sha(1|224|256|384|512)(sums?)?|md5(sums?)?|sums?)$|checksums?|sha(1|256|512)sums?)`)
+	signatureRegexp = regexp.MustCompile(`(?i)\.(sig|asc|gpg|pem|crt|cert|sigstore|minisig|p7s|sigstore\.json)$`)
+	sbomRegexp      = regexp.MustCompile(`(?i)(sbom|\.spdx(\.json)?$|\.cdx\.(json|xml)$|\.intoto\.jsonl$)`)
+	packageRegexp   = regexp.MustCompile(`(?i)\.(deb|rpm|apk|msi|pkg|dmg|snap|flatpak|appimage|nupkg|whl)$`)
+	debugRegexp     = regexp.MustCompile(`(?i)([-_.](debug|dbg|symbols?)([-_.]|$)|\.(dsym|pdb)(\.|$))`)
+	documentRegexp  = regexp.MustCompile(`(?i)\.(txt|md|json|ya?ml|html?|pdf|1|man)$`)
+)
+
+// archivePreferences scores the formats of the assets, the formats which are
+// easiest to extract (and the bare executables) are preferred
+var archivePreferences = []struct {
+	regexp *regexp.Regexp
+	score  int
+	format string
+}{
+	{regexp.MustCompile(`(?i)\.(tgz|tar\.gz)$`), 10, "tar.gz"},
+	{regexp.MustCompile(`(?i)\.(txz|tar\.xz)$`), 10, "tar.xz"},
+	{regexp.MustCompile(`(?i)\.(tzst|tar\.zst)$`), 10, "tar.zst"},
+	{regexp.MustCompile(`(?i)\.(tbz2|tar\.bz2)$`), 8, "tar.bz2"},
+	{regexp.MustCompile(`(?i)\.zip$`), 8, "zip"},
+	{regexp.MustCompile(`(?i)\.exe$`), 6, "executable"},
+	{regexp.MustCompile(`(?i)\.(gz|xz|zst|bz2)$`), 6, "compressed file"},
+	{regexp.MustCompile(`(?i)\.(tar|7z)$`), 4, "archive"},
+}
+
+// assetScore is the score of a release asset as the download for a platform,
+// along with the reasons for it
+type assetScore struct {
+	asset   *github.ReleaseAsset
+	score   int
+	reasons []string
+}
+
+// add changes the score, giving the reason for the change
+func (s *assetScore) add(points int, reason string) {
+	s.score += points
+	s.reasons = append(s.reasons, fmt.Sprintf("%+d %s", points, reason))
+}
+
+// scoreAsset rates the given asset as the download for the given platform:
+// naming the platform's OS, architecture and C library and being in a
+// preferred format count for it, naming another platform and being a checksum, signature,
+// SBOM, package or debug symbols count against it
+func scoreAsset(asset *github.ReleaseAsset, p platform) assetScore {
+	s := assetScore{asset: asset}
+	name := asset.GetName()
+
+	s.add(platformScore(name, p.os, knownOSes, osRegexpFor))
+	s.add(platformScore(name, p.arch, knownArches, archRegexpFor))
+	if points, reason := libcScore(name, p.libc); points != 0 {
+		s.add(points, reason)
+	}
+
+	switch {
+	case checksumRegexp.MatchString(name):
+		s.add(-100, "checksum")
+	case signatureRegexp.MatchString(name):
+		s.add(-100, "signature")
+	case sbomRegexp.MatchString(name):
+		s.add(-100, "sbom")
+	case packageRegexp.MatchString(name):
+		s.add(-30, "package")
+	case documentRegexp.MatchString(name):
+		s.add(-50, "document")
+	default:
+		s.add(formatScore(name, p))
+	}
+	if debugRegexp.MatchString(name) {
+		s.add(-40, "debug symbols")
+	}
+	return s
+}
+
+// platformScore rates how well the given asset name matches the target OS or
+// architecture: naming the target is good, naming none of the known ones is
+// neutral, naming another is bad. The reason for the score is returned with
+// it.
+func platformScore(name string, target string, known []string, regexpFor func(string) *regexp.Regexp) (int, string) {
+	if regexpFor(target).MatchString(name) {
+		return 20, "names " + target
+	}
+	for _, other := range known {
+		if other != target && regexpFor(other).MatchString(name) {
+			return -50, "names " + other
+		}
+	}
+	return 0, "doesn't name " + target
+}
+
+// libcScore rates how well the given asset name matches the target C library,
+// if it's known. glibc builds don't run on musl systems, while musl builds are
+// usually static and run on either.
+func libcScore(name string, libc string) (int, string) {
+	if libc == "" {
+		return 0, ""
+	}
+	if libcRegexpFor(libc).MatchString(name) {
+		return 10, "names " + libc
+	}
+	switch {
+	case libc == "musl" && libcRegexpFor("glibc").MatchString(name):
+		return -40, "names glibc"
+	case libc == "glibc" && libcRegexpFor("musl").MatchString(name):
+		return 5, "names musl"
+	}
+	return 0, ""
+}
+
+// formatScore rates the format of the given asset name, returning the score
+// and the format's description
+func formatScore(name string, p platform) (int, string) {
+	for _, pref := range archivePreferences {
+		if pref.regexp.MatchString(name) {
+			if pref.format == "zip" && p.os == "windows" {
+				return pref.score + 2, pref.format + " on windows"
+			}
+			return pref.score, pref.format
+		}
+	}
+	// names without an extension after the platform are taken to be executables
+	if !strings.Contains(name[strings.LastIndexAny(name, "-_")+1:], ".") {
+		return 6, "executable"
+	}
+	return 0, "unknown format"
+}
+
+// autoSelectAsset picks the best of the given assets for the given platform.
+// The ranking is explained at the debug log level. It's an error if no asset
+// has a positive score or if the best two are tied.
+func autoSelectAsset(assets []*github.ReleaseAsset, p platform) (*github.ReleaseAsset, error) {
+	if len(assets) == 0 {
+		return nil, fmt.Errorf("there are no downloads to choose from for %s", p)
+	}
+	scores := make([]assetScore, len(assets))
+	for i, asset := range assets {
+		scores[i] = scoreAsset(asset, p)
+	}
+	sort.SliceStable(scores, func(i, j int) bool { return scores[i].score > scores[j].score })
+
+	log.Debugf("ranking %d downloads for %s:", len(scores), p)
+	for _, s := range scores {
+		log.Debugf("%4d %s (%s)", s.score, s.asset.GetName(), strings.Join(s.reasons, ", "))
+	}
+
+	best := scores[0]
+	if best.score <= 0 {
+		return nil, fmt.Errorf("none of the %d downloads looks like one for %s, the best was %s (score %d)", len(scores), p, best.asset.GetName(), best.score)
+	}
+	if len(scores) > 1 && scores[1].score == best.score {
+		return nil, fmt.Errorf("can't choose between %s and %s for %s, use a -f flag to pick one", best.asset.GetName(), scores[1].asset.GetName(), p)
+	}
+	log.Debugf("picked %s for %s", best.asset.GetName(), p)
+	return best.asset, nil
+}
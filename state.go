@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// stateFileName is the name of the file in the data directory which records
+// the installed tools
+const stateFileName = "installed.json"
+
+// installedFile is a file placed by an install
+type installedFile struct {
+	Path   string `json:"path"`   // absolute path of the installed file
+	Digest string `json:"digest"` // sha256 digest of the file as it was installed
+}
+
+// installedVersion is a release of a tool which was installed into the
+// versioned store
+type installedVersion struct {
+	Tag         string          `json:"tag"`          // tag of the installed release
+	Asset       string          `json:"asset"`        // name of the installed release asset
+	AssetID     int64           `json:"asset_id"`     // github's ID for the release asset
+	Digest      string          `json:"digest"`       // sha256 digest of the release asset
+	Files       []installedFile `json:"files"`        // the executables in the store
+	InstalledAt time.Time       `json:"installed_at"` // when the install finished
+}
+
+// installRecord describes an installed tool: the versions of it in the
+// versioned store and the active one, which the bin directory links to
+type installRecord struct {
+	Repo     string             `json:"repo"`               // owner/repo
+	Tag      string             `json:"tag"`                // tag of the active version
+	Previous string             `json:"previous,omitempty"` // tag of the version which was active before, for rollbacks
+	Held     bool               `json:"held,omitempty"`     // the active version was chosen with use or rollback, upgrades leave it alone
+	Links    []string           `json:"links"`              // the symlinks to the active version in the bin directory
+	Dirs     []string           `json:"dirs,omitempty"`     // directories the install created, deepest first
+	Versions []installedVersion `json:"versions"`           // the versions in the store
+
+	// the options the install used, upgrades reuse them
+	BinDir  string   `json:"bin_dir"`
+	Pin     string   `json:"pin,omitempty"`
+	Filters []string `json:"filters,omitempty"`
+	Keep    []string `json:"keep,omitempty"`
+	Name    string   `json:"name,omitempty"`
+}
+
+// installedPaths returns the paths of the links the install placed in the
+// bin directory
+func (r *installRecord) installedPaths() []string {
+	return r.Links
+}
+
+// version returns the installed version with the given tag, or nil if that
+// version isn't in the store
+func (r *installRecord) version(tag string) *installedVersion {
+	for i := range r.Versions {
+		if r.Versions[i].Tag == tag {
+			return &r.Versions[i]
+		}
+	}
+	return nil
+}
+
+// active returns the version the bin directory links to
+func (r *installRecord) active() *installedVersion {
+	if v := r.version(r.Tag); v != nil {
+		return v
+	}
+	return &installedVersion{Tag: r.Tag}
+}
+
+// addVersion records a version added to the store, replacing any earlier
+// install of the same tag
+func (r *installRecord) addVersion(v installedVersion) {
+	if existing := r.version(v.Tag); existing != nil {
+		*existing = v
+		return
+	}
+	r.Versions = append(r.Versions, v)
+}
+
+// tags returns the tags of the versions in the store
+func (r *installRecord) tags() []string {
+	tags := make([]string, len(r.Versions))
+	for i, v := range r.Versions {
+		tags[i] = v.Tag
+	}
+	return tags
+}
+
+// options returns the installOptions to upgrade the install with, which may
+// replace the links it placed
+func (r *installRecord) options() installOptions {
+	owned := make(map[string]bool)
+	for _, path := range r.installedPaths() {
+		owned[path] = true
+	}
+	return installOptions{
+		binDir:  r.BinDir,
+		filters: r.Filters,
+		pin:     r.Pin,
+		keep:    r.Keep,
+		name:    r.Name,
+		owned:   owned,
+	}
+}
+
+// installState is the set of installs recorded in the state file
+type installState struct {
+	dir      string // the data directory, which holds the state file and the store
+	path     string
+	Installs []installRecord `json:"installs"`
+}
+
+// dataDir returns the directory ghlatest keeps its state in,
+// $XDG_DATA_HOME/ghlatest or ~/.local/share/ghlatest
+func dataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, PROG), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine the home directory for the state file; error: %s", err)
+	}
+	return filepath.Join(home, ".local", "share", PROG), nil
+}
+
+// loadState reads the state file in the user's data directory, a missing
+// file is an empty state
+func loadState() (*installState, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	return loadStateAt(dir)
+}
+
+// loadStateAt reads the state file in the given data directory
+func loadStateAt(dir string) (*installState, error) {
+	state := &installState{dir: dir, path: filepath.Join(dir, stateFileName), Installs: make([]installRecord, 0)}
+
+	doc, err := os.ReadFile(state.path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(doc, state); err != nil {
+		return nil, fmt.Errorf("reading the state file \"%s\" failed; error: %s", state.path, err)
+	}
+	return state, nil
+}
+
+// save writes the state file, replacing the previous one with a rename so
+// that it's never left partially written
+func (s *installState) save() error {
+	doc, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(s.path), "."+stateFileName+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(append(doc, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path)
+	}
+	if err != nil {
+		return fmt.Errorf("writing the state file \"%s\" failed; error: %s", s.path, err)
+	}
+	return nil
+}
+
+// find returns the install of the given repo, or nil if it isn't installed
+func (s *installState) find(repo string) *installRecord {
+	for i := range s.Installs {
+		if s.Installs[i].Repo == repo {
+			return &s.Installs[i]
+		}
+	}
+	return nil
+}
+
+// remove drops the install of the given repo from the state
+func (s *installState) remove(repo string) {
+	for i := range s.Installs {
+		if s.Installs[i].Repo == repo {
+			s.Installs = append(s.Installs[:i], s.Installs[i+1:]...)
+			return
+		}
+	}
+}
+
+// record adds the given install, replacing any earlier install of its repo
+func (s *installState) record(rec installRecord) {
+	if existing := s.find(rec.Repo); existing != nil {
+		*existing = rec
+		return
+	}
+	s.Installs = append(s.Installs, rec)
+	sort.Slice(s.Installs, func(i, j int) bool { return s.Installs[i].Repo < s.Installs[j].Repo })
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// storeDir returns the directory of the versioned store in the given data
+// directory which holds the installed versions of the given repo
+// (owner/repo), e.g. $XDG_DATA_HOME/ghlatest/pkgs/owner/repo
+func storeDir(dataDir string, repo string) string {
+	owner, name, _ := strings.Cut(repo, "/")
+	return filepath.Join(dataDir, "pkgs", owner, name)
+}
+
+// versionDir returns the directory of the versioned store in the given data
+// directory which holds the given version of the given repo
+func versionDir(dataDir string, repo string, tag string) (string, error) {
+	name := strings.NewReplacer("/", "_", `\`, "_").Replace(tag)
+	if name == "" || name == "." || name == ".." {
+		return "", fmt.Errorf("can't store the release tagged \"%s\"", tag)
+	}
+	return filepath.Join(storeDir(dataDir, repo), name), nil
+}
+
+// isStoreLink reports whether the given path is a symlink to one of the
+// files the given install placed in the store
+func isStoreLink(rec *installRecord, path string) bool {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return false
+	}
+	for _, v := range rec.Versions {
+		for _, f := range v.Files {
+			if filepath.Clean(target) == f.Path {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// storeLinks returns the links in the bin directory for the given version
+// of the install, along with the files in the store they point to
+func storeLinks(rec *installRecord, v *installedVersion) (links []string, targets []string) {
+	for _, f := range v.Files {
+		links = append(links, filepath.Join(rec.BinDir, filepath.Base(f.Path)))
+		targets = append(targets, f.Path)
+	}
+	return links, targets
+}
+
+// activate points the bin directory links of the given install at the
+// version with the given tag, which must be in the store. Existing files in
+// the bin directory are only replaced if they're links into the store, were
+// placed by the install, or opts.overwrite is set. Links to the previously
+// active version which the new one doesn't replace are removed. Each link is
+// replaced with a rename, so the bin directory always has a complete
+// version.
+func activate(rec *installRecord, tag string, opts installOptions) error {
+	v := rec.version(tag)
+	if v == nil {
+		return fmt.Errorf("%s %s isn't in the store", rec.Repo, tag)
+	}
+	owned := make(map[string]bool)
+	for _, link := range rec.Links {
+		owned[link] = true
+	}
+
+	// check for conflicts before anything is changed
+	links, targets := storeLinks(rec, v)
+	for _, link := range links {
+		if _, err := os.Lstat(link); err == nil && !owned[link] && !opts.owned[link] && !isStoreLink(rec, link) && !opts.overwrite {
+			return fmt.Errorf("\"%s\" already exists; use --overwrite to replace it", link)
+		}
+	}
+
+	current := make(map[string]bool)
+	for i, link := range links {
+		tmp := filepath.Join(filepath.Dir(link), ".ghlatest-link-"+filepath.Base(link))
+		os.Remove(tmp)
+		if err := os.Symlink(targets[i], tmp); err != nil {
+			return fmt.Errorf("linking \"%s\" failed; error: %s", link, err)
+		}
+		if err := os.Rename(tmp, link); err != nil {
+			os.Remove(tmp)
+			return fmt.Errorf("linking \"%s\" failed; error: %s", link, err)
+		}
+		log.Infof("linked %s => %s", link, targets[i])
+		current[link] = true
+	}
+	for _, link := range rec.Links {
+		if !current[link] && isStoreLink(rec, link) {
+			if err := os.Remove(link); err != nil {
+				return fmt.Errorf("removing \"%s\" failed; error: %s", link, err)
+			}
+			log.Infof("removed %s, it isn't part of %s %s", link, rec.Repo, tag)
+		}
+	}
+
+	if rec.Tag != "" && rec.Tag != tag {
+		rec.Previous = rec.Tag
+	}
+	rec.Tag = tag
+	rec.Links = links
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/go-github/v33/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// syncResult is the outcome of syncing one of the manifest's tools
+type syncResult struct {
+	repo   string // owner/repo
+	tag    string // the tag the tool should be at, if it was found
+	status string
+	err    error
+}
+
+// syncTools brings the installs in the manifest's bin directory in line with
+// the manifest, running up to the given number of installs at once. The
+// installs are recorded in a data directory inside the bin directory, apart
+// from the user's installs. Given a lock file, the tools are installed from
+// exactly the release assets it records, whose digests must match. With
+// dryRun set nothing is changed. The results are in the order of the
+// manifest.
+func syncTools(m *manifest, lock *lockFile, jobs int, dryRun bool) ([]syncResult, error) {
+	binDir, err := m.binDir()
+	if err != nil {
+		return nil, err
+	}
+	dataDir := filepath.Join(binDir, "."+PROG)
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	results := make([]syncResult, len(m.Tools))
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, tool := range m.Tools {
+		wg.Add(1)
+		go func(i int, tool manifestTool) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = syncTool(tool, tool.options(binDir, dataDir), lock, dryRun)
+			if results[i].err != nil {
+				log.Errorf("syncing %s failed; error: %s", results[i].repo, results[i].err)
+			}
+		}(i, tool)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// syncTool installs the release of the given tool which the manifest (or the
+// lock file, if one is given) calls for, unless it's already the active
+// version. Versions which are already in the store are only linked.
+func syncTool(tool manifestTool, opts installOptions, lock *lockFile, dryRun bool) syncResult {
+	owner, repo, _ := repoURLInfo(tool.Repo)
+	res := syncResult{repo: owner + "/" + repo}
+
+	var release *github.RepositoryRelease
+	var asset *github.ReleaseAsset
+	var err error
+	if lock != nil {
+		locked := lock.find(res.repo)
+		if locked == nil {
+			res.err = fmt.Errorf("%s isn't in %s", res.repo, lock.path)
+			return res
+		}
+		release, asset, opts.digest, err = locked.target(currentPlatform())
+	} else {
+		release, err = tool.release(owner, repo)
+	}
+	if err != nil {
+		res.err = err
+		return res
+	}
+	res.tag = release.GetTagName()
+
+	stateMu.Lock()
+	state, err := loadStateAt(opts.dataDir)
+	stateMu.Unlock()
+	if err != nil {
+		res.err = err
+		return res
+	}
+	var prevTag string
+	if prev := state.find(res.repo); prev != nil {
+		prevTag = prev.Tag
+		opts.owned = prev.options().owned
+		// a stored version of another asset than the locked one is replaced
+		stored := prev.version(res.tag)
+		if stored != nil && opts.digest != "" && stored.Digest != opts.digest {
+			log.Warnf("%s %s in the store isn't the locked asset, it will be reinstalled", res.repo, res.tag)
+			stored = nil
+		}
+		if stored != nil && prev.Tag == res.tag && linksIntact(prev) {
+			res.status = "current"
+			return res
+		}
+		if stored != nil {
+			res.status = "switched from " + prev.Tag
+			if prev.Tag == res.tag {
+				res.status = "relinked"
+			}
+			if !dryRun {
+				res.err = activateVersion(opts.dataDir, res.repo, res.tag, opts)
+			}
+			return res
+		}
+	}
+
+	if asset == nil {
+		if asset, err = resolveAsset(release, opts); err != nil {
+			res.err = err
+			return res
+		}
+	}
+	switch {
+	case prevTag == "":
+		res.status = "installed"
+	case prevTag == res.tag:
+		res.status = "reinstalled"
+	default:
+		res.status = "upgraded from " + prevTag
+	}
+	if dryRun {
+		log.Infof("would download %s", asset.GetBrowserDownloadURL())
+		return res
+	}
+	_, res.err = installRelease(owner, repo, release, asset, opts)
+	return res
+}
+
+// linksIntact reports whether all the links of the given install are still
+// in place
+func linksIntact(rec *installRecord) bool {
+	if len(rec.Links) != len(rec.active().Files) {
+		return false
+	}
+	for _, link := range rec.Links {
+		if _, err := os.Stat(link); err != nil || !isStoreLink(rec, link) {
+			return false
+		}
+	}
+	return true
+}
+
+// activateVersion links the bin directory to the given version of the given
+// repo's install in the given data directory and saves the state
+func activateVersion(dataDir string, repo string, tag string, opts installOptions) error {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	state, err := loadStateAt(dataDir)
+	if err != nil {
+		return err
+	}
+	rec := state.find(repo)
+	if rec == nil {
+		return fmt.Errorf("%s isn't installed", repo)
+	}
+	log.Infof("switching %s from %s to %s", rec.Repo, rec.Tag, tag)
+	if err := activate(rec, tag, opts); err != nil {
+		return err
+	}
+	return state.save()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v33/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// updateCheck is the result of looking for an update to an install
+type updateCheck struct {
+	rec     *installRecord
+	release *github.RepositoryRelease // the release the install should be at
+	err     error
+}
+
+// outdated reports whether a newer release than the installed one is
+// available. Installs whose version was chosen with use or rollback are held
+// at it, they aren't outdated until upgrade names them.
+func (u updateCheck) outdated() bool {
+	return u.err == nil && !u.rec.Held && u.release.GetTagName() != u.rec.Tag
+}
+
+// held reports whether the install is held at a version other than the one
+// it would be upgraded to
+func (u updateCheck) held() bool {
+	return u.err == nil && u.rec.Held && u.release.GetTagName() != u.rec.Tag
+}
+
+// status describes the outcome of the check
+func (u updateCheck) status() string {
+	switch {
+	case u.err != nil:
+		return "error"
+	case u.outdated():
+		return "outdated"
+	case u.held():
+		return "held"
+	default:
+		return "current"
+	}
+}
+
+// available returns the tag of the release the install should be at
+func (u updateCheck) available() string {
+	if u.err != nil {
+		return "-"
+	}
+	return u.release.GetTagName()
+}
+
+// checkUpdates asks github for the release each of the given installs should
+// be at: the latest release, or the newest release matching the install's
+// pin. Failed checks are logged and recorded in the results.
+func checkUpdates(recs []*installRecord) []updateCheck {
+	checks := make([]updateCheck, 0, len(recs))
+	for _, rec := range recs {
+		check := updateCheck{rec: rec}
+		owner, repo, _ := strings.Cut(rec.Repo, "/")
+		if check.release, check.err = resolveRelease(owner, repo, rec.Pin); check.err != nil {
+			log.Errorf("checking %s for updates failed; error: %s", rec.Repo, check.err)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// selectInstalls returns the installs of the given repos (as owner/repo or
+// github URLs), or every install if none are given
+func selectInstalls(state *installState, repoArgs []string) ([]*installRecord, error) {
+	recs := make([]*installRecord, 0)
+	if len(repoArgs) == 0 {
+		for i := range state.Installs {
+			recs = append(recs, &state.Installs[i])
+		}
+		return recs, nil
+	}
+	for _, arg := range repoArgs {
+		owner, repo, err := repoURLInfo(arg)
+		if err != nil {
+			return nil, err
+		}
+		rec := state.find(owner + "/" + repo)
+		if rec == nil {
+			return nil, fmt.Errorf("%s/%s isn't installed", owner, repo)
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+// upgradeInstall re-runs the install of the given check's repo with the
+// release it should be at, using the options of the original install
+func upgradeInstall(check updateCheck) (*installRecord, error) {
+	owner, repo, _ := strings.Cut(check.rec.Repo, "/")
+	opts := check.rec.options()
+	asset, err := resolveAsset(check.release, opts)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("upgrading %s from %s to %s", check.rec.Repo, check.rec.Tag, check.release.GetTagName())
+	return installRelease(owner, repo, check.release, asset, opts)
+}
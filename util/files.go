@@ -1,6 +1,8 @@
 package util
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/fs"
@@ -58,6 +60,25 @@ func flagsString(flags int) string {
 	return strings.Join(enabledFlags, "|")
 }
 
+// OpenURL requests the given url and returns the body of the response,
+// which the caller must close. Responses other than 200 OK are errors.
+func OpenURL(url string) (io.ReadCloser, error) {
+	// Get the data
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check server response
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("non-OK HTTP response status: %s", resp.Status)
+	}
+	log.Debugf("disposition: %s\n", resp.Header["Content-Disposition"])
+
+	return resp.Body, nil
+}
+
 // DownloadFile places the contents of the given url into a local file at the
 // given path with the given mode. If the overwrite flag is set then any
 // existing files with conflicting names will be overwritten
@@ -65,22 +86,67 @@ func DownloadFile(url string, filePath string, mode os.FileMode, overwrite bool)
 	// generally applicable utility for downloading the contents of a url to
 	// a given file path.
 	// copied (with minor mod.) from: https://stackoverflow.com/a/33853856
-
-	// Get the data
-	resp, err := http.Get(url)
+	body, err := OpenURL(url)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	defer body.Close()
 
-	// Check server response
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("non-OK HTTP response status: %s", resp.Status)
+	_, err = NewFileFromSource(filePath, mode, overwrite, body)
+	return err
+}
+
+// FileDigest returns the sha256 digest of the contents of the file at the
+// given path, in the form "sha256:<hex>"
+func FileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
 	}
-	log.Debugf("disposition: %s\n", resp.Header["Content-Disposition"])
+	defer f.Close()
+	return readerDigest(f)
+}
 
-	_, err = NewFileFromSource(filePath, mode, overwrite, resp.Body)
-	return err
+// URLDigest returns the sha256 digest of the contents of the given url, in
+// the form "sha256:<hex>", without saving them
+func URLDigest(url string) (string, error) {
+	body, err := OpenURL(url)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	return readerDigest(body)
+}
+
+// readerDigest returns the sha256 digest of everything read from r
+func readerDigest(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// PlanFile reports what creating a file (or directory, if isDir is set) at
+// the given path would do without changing the filesystem: "create" if
+// nothing exists there, "exists" for a directory which is already present,
+// or "overwrite" if the overwrite flag is set. An error is returned if the
+// path is taken and the overwrite flag isn't set.
+func PlanFile(path string, isDir bool, overwrite bool) (string, error) {
+	stats, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return "create", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if isDir && stats.IsDir() {
+		return "exists", nil
+	}
+	if !overwrite {
+		return "", fmt.Errorf("\"%s\" already exists; use --overwrite to replace it", path)
+	}
+	return "overwrite", nil
 }
 
 // NormalizeFilePath is a utility function that rewrites file paths specified
@@ -117,9 +183,22 @@ func NormalizeFilePath(path string) string {
 // overwritten or and error produced, the source provides the data that
 // will be written to the new file. The bytes written are returned.
 func NewFileFromSource(path string, mode fs.FileMode, overwrite bool, source io.Reader) (int64, error) {
+	return newFile(path, mode, overwrite, source, false)
+}
+
+// NewSparseFileFromSource works like [NewFileFromSource] but it seeks over
+// blocks of zeros in the source rather than writing them, so that they become
+// holes in the output file on filesystems which support sparse files.
+func NewSparseFileFromSource(path string, mode fs.FileMode, overwrite bool, source io.Reader) (int64, error) {
+	return newFile(path, mode, overwrite, source, true)
+}
+
+// newFile implements [NewFileFromSource] and [NewSparseFileFromSource]
+func newFile(path string, mode fs.FileMode, overwrite bool, source io.Reader, sparse bool) (int64, error) {
 	openFlags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
 	if overwrite {
 		openFlags &^= os.O_EXCL // remove the "don't overwrite" flag
+		openFlags |= os.O_TRUNC
 	}
 
 	outputFile, err := os.OpenFile(path, openFlags, mode)
@@ -127,8 +206,18 @@ func NewFileFromSource(path string, mode fs.FileMode, overwrite bool, source io.
 		log.Errorf("Opening output file \"%s\" with flags %s failed; error: %s", path, flagsString(openFlags), err)
 		return 0, err
 	}
+	defer outputFile.Close()
 
-	bytes, err := io.Copy(outputFile, source)
+	var bytes int64
+	if sparse {
+		hw := &holeWriter{f: outputFile}
+		bytes, err = io.Copy(hw, source)
+		if err == nil {
+			err = hw.finish()
+		}
+	} else {
+		bytes, err = io.Copy(outputFile, source)
+	}
 	if err != nil {
 		log.Errorf("Writing to output file \"%s\" failed; error: %s", path, err)
 		return bytes, err
@@ -139,10 +228,64 @@ func NewFileFromSource(path string, mode fs.FileMode, overwrite bool, source io.
 	return bytes, nil
 }
 
+// holeBlockSize is the granularity at which holeWriter looks for zeros
+const holeBlockSize = 4096
+
+// holeWriter writes to a file, seeking over whole blocks of zeros instead of
+// writing them
+type holeWriter struct {
+	f    *os.File
+	size int64 // logical size of the file written so far
+}
+
+// Write implements io.Writer
+func (w *holeWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := len(p)
+		if n > holeBlockSize {
+			n = holeBlockSize
+		}
+		block := p[:n]
+		if isZero(block) {
+			if _, err := w.f.Seek(int64(n), io.SeekCurrent); err != nil {
+				return written, err
+			}
+		} else if _, err := w.f.Write(block); err != nil {
+			return written, err
+		}
+		w.size += int64(n)
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// finish sets the file's size, which is needed when it ends with a hole
+func (w *holeWriter) finish() error {
+	return w.f.Truncate(w.size)
+}
+
+// isZero reports whether the given buffer contains only zero bytes
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 // NewDirectory wraps os.Mkdir to provide standardized directory creation and
-// logging
+// logging. Directories which already exist are not treated as an error.
 func NewDirectory(path string, mode fs.FileMode) (err error) {
 	err = os.Mkdir(path, mode)
+	if err != nil && os.IsExist(err) {
+		if stats, statErr := os.Stat(path); statErr == nil && stats.IsDir() {
+			log.Debugf("directory \"%s\" already exists", path)
+			return nil
+		}
+	}
 	if err != nil {
 		log.Infof("Creating directory \"%s\" failed; error: %s", path, err)
 		return
@@ -151,3 +294,17 @@ func NewDirectory(path string, mode fs.FileMode) (err error) {
 	log.Infof("created directory:\"%s\"; mode:%#o", path, mode)
 	return
 }
+
+// NewParentDirectories wraps os.MkdirAll to create any missing parent
+// directories of the given file path with the given mode
+func NewParentDirectories(path string, mode fs.FileMode) error {
+	parent := filepath.Dir(path)
+	if parent == "." || parent == "/" {
+		return nil
+	}
+	if err := os.MkdirAll(parent, mode); err != nil {
+		log.Errorf("Creating parent directories for \"%s\" failed; error: %s", path, err)
+		return err
+	}
+	return nil
+}
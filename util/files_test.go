@@ -0,0 +1,51 @@
+package util
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+)
+
+func TestNewSparseFileFromSource(t *testing.T) {
+	hole := make([]byte, 3*holeBlockSize)
+	data := bytes.Repeat([]byte("data"), holeBlockSize/2)
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+	tests := []struct {
+		name     string
+		contents []byte
+	}{
+		{"empty", nil},
+		{"no holes", data},
+		{"hole at the start", join(hole, data)},
+		{"hole at the end", join(data, hole)},
+		{"holes at both ends", join(hole, data, hole)},
+		{"only a hole", hole},
+		{"short zero tail", join(data, make([]byte, 100))},
+	}
+	dir := t.TempDir()
+	for i, tc := range tests {
+		// the contents are read in uneven pieces, which don't line up with
+		// the blocks
+		for j, r := range []io.Reader{bytes.NewReader(tc.contents), iotest.HalfReader(bytes.NewReader(tc.contents))} {
+			path := filepath.Join(dir, string(rune('a'+i))+string(rune('0'+j)))
+			n, err := NewSparseFileFromSource(path, 0644, false, r)
+			if err != nil {
+				t.Errorf("%s: %s", tc.name, err)
+				continue
+			}
+			if n != int64(len(tc.contents)) {
+				t.Errorf("%s: wrote %d bytes, expected %d", tc.name, n, len(tc.contents))
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tc.contents) {
+				t.Errorf("%s: the file's contents differ, it has %d bytes, expected %d", tc.name, len(got), len(tc.contents))
+			}
+		}
+	}
+}
@@ -3,6 +3,7 @@ package util
 import (
 	"fmt"
 	"regexp"
+	"strings"
 )
 
 // FilterSet contains compiled regular expressions which identify files to be
@@ -21,3 +22,77 @@ func CompileFilters(filterStrings []string) (FilterSet, error) {
 	}
 	return filters, nil
 }
+
+// RenameSeparator separates the regular expression from the replacement in
+// a keep rule string such as `tool_linux_amd64$=>tool`
+const RenameSeparator = "=>"
+
+// KeepRule is a compiled --keep argument. Files with paths matching Filter
+// are selected; if Rename is set, the matching portion of the path is
+// replaced with Replacement (which may refer to capture groups, e.g. $1)
+type KeepRule struct {
+	Filter      *regexp.Regexp
+	Replacement string
+	Rename      bool
+}
+
+// KeepSet contains the rules which identify (and optionally rename) files to
+// be selected. If the KeepSet is empty all files will be selected unchanged
+type KeepSet []KeepRule
+
+// CompileKeepRules takes a list of keep rule strings, each either a plain
+// regular expression or a "regex=>replacement" mapping, and compiles them
+// into a [KeepSet].
+func CompileKeepRules(ruleStrings []string) (KeepSet, error) {
+	rules := make(KeepSet, 0, len(ruleStrings))
+	for _, ruleStr := range ruleStrings {
+		var rule KeepRule
+		filterStr := ruleStr
+		if i := strings.Index(ruleStr, RenameSeparator); i >= 0 {
+			filterStr = ruleStr[:i]
+			rule.Replacement = ruleStr[i+len(RenameSeparator):]
+			rule.Rename = true
+			if rule.Replacement == "" {
+				return nil, fmt.Errorf("keep rule \"%s\" has an empty replacement", ruleStr)
+			}
+		}
+		filter, err := regexp.Compile(filterStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile keep rule: \"%s\" - error: %s", ruleStr, err)
+		}
+		rule.Filter = filter
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Select reports whether the given path is selected by the KeepSet and
+// returns the path the file should be written to. The first matching rule
+// determines the output path. An empty KeepSet selects every path unchanged.
+func (k KeepSet) Select(path string) (string, bool) {
+	if len(k) == 0 {
+		return path, true
+	}
+	for _, rule := range k {
+		if !rule.Filter.MatchString(path) {
+			continue
+		}
+		if !rule.Rename {
+			return path, true
+		}
+		return NormalizeFilePath(rule.Filter.ReplaceAllString(path, rule.Replacement)), true
+	}
+	return "", false
+}
+
+// Rename returns the given path rewritten by the first matching rename rule
+// in the KeepSet, paths which don't match any rename rule are returned
+// unchanged.
+func (k KeepSet) Rename(path string) string {
+	for _, rule := range k {
+		if rule.Rename && rule.Filter.MatchString(path) {
+			return NormalizeFilePath(rule.Filter.ReplaceAllString(path, rule.Replacement))
+		}
+	}
+	return path
+}